@@ -1,29 +1,61 @@
 package allocations
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"math"
+	"sort"
 
 	"github.com/samkreter/portfoli/asset"
+	"github.com/samkreter/portfoli/pkg/money"
+)
+
+const (
+	// percentTolerance is how far the summed desired percents may be from 1
+	percentTolerance = 0.0001
 )
 
 // AllocationPlan hold the total plane for all asset allocations
 type AllocationPlan struct {
-	Name        string
-	Allocations []*AssetAllocation
+	Name        string             `json:"name"`
+	Description string             `json:"description,omitempty"`
+	Allocations []*AssetAllocation `json:"allocations"`
+
+	// Classes optionally defines the plan as class, subclass and fund targets, see Flatten
+	Classes []ClassTarget `json:"classes,omitempty"`
+
+	// Locations overrides which account tax types each asset class is held in, see Locate
+	Locations []LocationPreference `json:"locations,omitempty"`
+
+	// Cash to keep uninvested, a percent of the whole portfolio plus a fixed reserve, see CashTarget
+	CashPercent float64     `json:"cashPercent,omitempty"`
+	CashReserve money.Money `json:"cashReserve,omitempty"`
+
+	// Cash is the cash held outside of the plan's assets, see SetCash
+	Cash money.Money `json:"-"`
 }
 
 // AssetAllocation holds the allocation plan for a single asset
 type AssetAllocation struct {
 	// NonMutating
-	Symbol         string
-	DesiredPercent float64
+	Symbol         string  `json:"symbol"`
+	DesiredPercent float64 `json:"desiredPercent"`
+
+	// Equivalents are interchangeable funds counted towards this asset, Symbol
+	// is preferred for new purchases. AccountSymbols overrides the preferred
+	// fund for accounts that can't buy Symbol, e.g. a 401k.
+	Equivalents    []string          `json:"equivalents,omitempty"`
+	AccountSymbols map[string]string `json:"accountSymbols,omitempty"`
+
+	// Optional tolerance bands, see Band
+	AbsoluteBand float64 `json:"absoluteBand,omitempty"`
+	RelativeBand float64 `json:"relativeBand,omitempty"`
 
 	// Mutatable
-	CurrPercent  float64
-	CurrValue    float64
-	DesiredValue float64
+	CurrPercent  float64     `json:"-"`
+	CurrValue    money.Money `json:"-"`
+	DesiredValue money.Money `json:"-"`
 }
 
 // AssetClassPercent shows the percent of an asset class
@@ -32,45 +64,114 @@ type AssetClassPercent struct {
 	PercentOfPlan float64
 }
 
-// GetAllocation gets an allocation plan by name
+// GetAllocation gets an allocation plan by name. Plans in the user's plans
+// directory take precedence over the built-in plans of the same name.
 func GetAllocation(allocationPlanName string) (AllocationPlan, error) {
-	var allocationPlane AllocationPlan
+	userPlans, err := loadUserPlans()
+	if err != nil {
+		return AllocationPlan{}, err
+	}
 
-	switch allocationPlanName {
-	case "AllWeather":
-		allocationPlane = getAllWeatherAllocation()
-		if err := allocationPlane.Validate(); err != nil {
-			return allocationPlane, err
-		}
+	if allocationPlane, ok := userPlans[allocationPlanName]; ok {
 		return allocationPlane, nil
-	case "Swensen":
-		allocationPlane = getSwensenAllocation()
-		if err := allocationPlane.Validate(); err != nil {
-			return allocationPlane, err
+	}
+
+	getPlan, ok := builtinAllocations[allocationPlanName]
+	if !ok {
+		return AllocationPlan{}, fmt.Errorf("invalid allocation name: %s", allocationPlanName)
+	}
+
+	allocationPlane := getPlan()
+	if err := allocationPlane.Validate(); err != nil {
+		return allocationPlane, err
+	}
+
+	return allocationPlane, nil
+}
+
+// ListAllocations returns the names of all available allocation plans
+func ListAllocations() ([]string, error) {
+	userPlans, err := loadUserPlans()
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for name := range builtinAllocations {
+		if _, ok := userPlans[name]; !ok {
+			names = append(names, name)
 		}
-		return allocationPlane, nil
-	default:
-		return allocationPlane, fmt.Errorf("invalid allocation name: %s", allocationPlanName)
 	}
+	for name := range userPlans {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names, nil
 }
 
 // Validate ensures the allocation planes is valid
 func (plan AllocationPlan) Validate() error {
+	if plan.Name == "" {
+		return errors.New("allocation plan is missing a name")
+	}
+
+	if len(plan.Allocations) == 0 {
+		return fmt.Errorf("allocation plan %q has no allocations", plan.Name)
+	}
+
+	seen := map[string]bool{}
 	totalPercent := 0.0
 	for _, aAllocation := range plan.Allocations {
+		if aAllocation.Symbol == "" {
+			return fmt.Errorf("allocation plan %q has an allocation without a symbol", plan.Name)
+		}
+		for _, symbol := range aAllocation.Symbols() {
+			if seen[symbol] {
+				return fmt.Errorf("allocation plan %q lists %q more than once", plan.Name, symbol)
+			}
+			seen[symbol] = true
+		}
+
+		if aAllocation.DesiredPercent < 0 {
+			return fmt.Errorf("allocation plan %q has a negative percent for %q", plan.Name, aAllocation.Symbol)
+		}
+
+		if aAllocation.AbsoluteBand < 0 || aAllocation.RelativeBand < 0 {
+			return fmt.Errorf("allocation plan %q has a negative band for %q", plan.Name, aAllocation.Symbol)
+		}
+
 		totalPercent = totalPercent + aAllocation.DesiredPercent
 	}
 
-	if math.Round(totalPercent) != 1 {
-		return fmt.Errorf("allocation percentage is %d, should be 1", totalPercent)
+	if math.Abs(totalPercent-1) > percentTolerance {
+		return fmt.Errorf("allocation percentage is %f, should be 1", totalPercent)
+	}
+
+	if plan.CashPercent < 0 || plan.CashPercent >= 1 {
+		return fmt.Errorf("allocation plan %q has a cash percent of %f, should be at least 0 and below 1", plan.Name, plan.CashPercent)
+	}
+	if plan.CashReserve < 0 {
+		return fmt.Errorf("allocation plan %q has a negative cash reserve", plan.Name)
 	}
 
 	return nil
 }
 
+// SetCurrValues sets the current value of each asset from the total value held
+// of each symbol, summing the asset's equivalents. Assets that are not held are set to 0.
+func (plan *AllocationPlan) SetCurrValues(values map[string]money.Money) {
+	for _, aAllocation := range plan.Allocations {
+		aAllocation.CurrValue = 0
+		for _, symbol := range aAllocation.Symbols() {
+			aAllocation.CurrValue += values[symbol]
+		}
+	}
+}
+
 // GetCurrTotalVal returns the current total value for the asset plan
-func (plan AllocationPlan) GetCurrTotalVal() float64 {
-	total := 0.0
+func (plan AllocationPlan) GetCurrTotalVal() money.Money {
+	total := money.Money(0)
 	for _, aAllocation := range plan.Allocations {
 		total = total + aAllocation.CurrValue
 	}
@@ -79,8 +180,8 @@ func (plan AllocationPlan) GetCurrTotalVal() float64 {
 }
 
 // GetCurrTotalVal returns the current total value for the asset plan
-func (plan AllocationPlan) GetDesiredTotalValue() float64 {
-	total := 0.0
+func (plan AllocationPlan) GetDesiredTotalValue() money.Money {
+	total := money.Money(0)
 	for _, aAllocation := range plan.Allocations {
 		total = total + aAllocation.DesiredValue
 	}
@@ -100,19 +201,41 @@ func (plan *AllocationPlan) UpdateDesiredValues() error {
 	biggestDiffAsset := plan.computeGreatestNegativeDiff()
 	if biggestDiffAsset.Symbol == "" {
 		log.Println("No Difference")
-		return nil
+	} else {
+		// Compute new desired values using new total
+		newTotal := biggestDiffAsset.CurrValue.Div(biggestDiffAsset.DesiredPercent)
+		plan.computeDesiredValues(newTotal)
 	}
 
-	// Compute new desired values using new total
-	newTotal := biggestDiffAsset.CurrValue / biggestDiffAsset.DesiredPercent
-	plan.computeDesiredValues(newTotal)
+	// Rounding to the cent must not turn the most overweight asset into a
+	// sell, and assets without a target are kept
+	for _, aAllocation := range plan.Allocations {
+		aAllocation.DesiredValue = money.Max(aAllocation.DesiredValue, aAllocation.CurrValue)
+	}
 
 	return plan.Validate()
 }
 
+// classWeights returns the class breakdown of each of the plan's assets,
+// blended funds are split across the classes of their components
+func (plan AllocationPlan) classWeights() map[string]map[asset.Class]float64 {
+	classWeights := map[string]map[asset.Class]float64{}
+	for _, aAllocation := range plan.Allocations {
+		weights, err := asset.ClassWeights(aAllocation.Symbol)
+		if err != nil {
+			log.Printf("Warning: %q isn't classified, add it to the assets file: %v", aAllocation.Symbol, err)
+			continue
+		}
+		classWeights[aAllocation.Symbol] = weights
+	}
+
+	return classWeights
+}
+
 // GetAssetClassTotal gets the total asset class percentages for the allocation plan
 func (plan AllocationPlan) GetAssetClassTotal() []AssetClassPercent {
 	assetClasses := asset.GetAssetClasses()
+	classWeights := plan.classWeights()
 
 	classPercents := []AssetClassPercent{}
 
@@ -122,15 +245,7 @@ func (plan AllocationPlan) GetAssetClassTotal() []AssetClassPercent {
 		}
 
 		for _, aAllocation := range plan.Allocations {
-			a, err := asset.GetAsset(aAllocation.Symbol)
-			if err != nil {
-				log.Printf("Warning: failed to get asset %q", aAllocation.Symbol)
-				continue
-			}
-
-			if a.Class == class {
-				classPercent.PercentOfPlan += aAllocation.CurrPercent
-			}
+			classPercent.PercentOfPlan += classWeights[aAllocation.Symbol][class] * aAllocation.CurrPercent
 		}
 
 		classPercents = append(classPercents, classPercent)
@@ -142,10 +257,15 @@ func (plan AllocationPlan) GetAssetClassTotal() []AssetClassPercent {
 // ComputeGreatestNegativeDiff gets the negitive off the current value
 func (plan *AllocationPlan) computeGreatestNegativeDiff() AssetAllocation {
 	// Find the biggest negative off current value
-	biggestDiff := 0.0
+	biggestDiff := money.Money(0)
 	var biggestDiffAsset AssetAllocation
 
 	for idx, aAllocation := range plan.Allocations {
+		// An asset without a target can't set the total, it's only held until it's sold
+		if aAllocation.DesiredPercent == 0 {
+			continue
+		}
+
 		diff := aAllocation.DesiredValue - aAllocation.CurrValue
 		if diff < biggestDiff {
 			biggestDiff = diff
@@ -160,19 +280,44 @@ func (plan *AllocationPlan) computeGreatestNegativeDiff() AssetAllocation {
 func (plan *AllocationPlan) computeCurrPercents() {
 	total := plan.GetCurrTotalVal()
 
-	if math.Round(total) == 0 {
+	if total.Abs() < money.Unit/2 {
 		return
 	}
 
 	for _, aAllocation := range plan.Allocations {
-		percent := aAllocation.CurrValue / total
-		aAllocation.CurrPercent = math.Round(percent*100) / 100
+		percent := aAllocation.CurrValue.Ratio(total)
+		aAllocation.CurrPercent = math.Round(percent*10000) / 10000
+	}
+}
+
+// ComputeDesiredValues updates the desired values based on the total and desired percent.
+// The total is split in whole cents so the desired values add up to it exactly.
+func (plan *AllocationPlan) computeDesiredValues(total money.Money) {
+	percents := make([]float64, len(plan.Allocations))
+	for idx, aAllocation := range plan.Allocations {
+		percents[idx] = aAllocation.DesiredPercent
+	}
+
+	for idx, value := range total.Allocate(percents) {
+		plan.Allocations[idx].DesiredValue = value
 	}
 }
 
-// ComputeDesiredValues updates the desired values based on the total and desired percent
-func (plan *AllocationPlan) computeDesiredValues(total float64) {
+// settle puts the cents of rounding between the desired values and total on
+// the biggest trade, so the trades add up to the amount moved exactly
+func (plan *AllocationPlan) settle(total money.Money) {
+	residual := total.Round() - plan.GetDesiredTotalValue()
+	if residual == 0 {
+		return
+	}
+
+	var biggest *AssetAllocation
 	for _, aAllocation := range plan.Allocations {
-		aAllocation.DesiredValue = total * aAllocation.DesiredPercent
+		if biggest == nil || (aAllocation.DesiredValue-aAllocation.CurrValue).Abs() > (biggest.DesiredValue-biggest.CurrValue).Abs() {
+			biggest = aAllocation
+		}
+	}
+	if biggest != nil {
+		biggest.DesiredValue += residual
 	}
 }
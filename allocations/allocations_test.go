@@ -1 +1,768 @@
 package allocations
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/samkreter/portfoli/pkg/money"
+	"github.com/samkreter/portfoli/portfolio"
+)
+
+func TestBuiltinAllocationsValidate(t *testing.T) {
+	for name, getPlan := range builtinAllocations {
+		if err := getPlan().Validate(); err != nil {
+			t.Errorf("built-in plan %q is invalid: %v", name, err)
+		}
+	}
+}
+
+func TestUserPlanOverridesBuiltin(t *testing.T) {
+	home, err := ioutil.TempDir("", "portfoli-home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+
+	plansDir := filepath.Join(home, "plans")
+	if err := os.Mkdir(plansDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	planJSON := `{"name": "Swensen", "allocations": [{"symbol": "VTI", "desiredPercent": 0.6}, {"symbol": "VGIT", "desiredPercent": 0.4}]}`
+	if err := ioutil.WriteFile(filepath.Join(plansDir, "swensen.json"), []byte(planJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("PORTFOLI_HOME", home)
+	defer os.Unsetenv("PORTFOLI_HOME")
+
+	plan, err := GetAllocation("Swensen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Allocations) != 2 {
+		t.Errorf("expected 2 allocations, got %d", len(plan.Allocations))
+	}
+}
+
+func TestLoadPlanFileRejectsInvalidTotal(t *testing.T) {
+	f, err := ioutil.TempFile("", "plan*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString(`{"allocations": [{"symbol": "VTI", "desiredPercent": 0.9}]}`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	if _, err := LoadPlanFile(f.Name()); err == nil {
+		t.Error("expected error for plan not adding up to 1")
+	}
+}
+
+func TestLoadPlanFileYAML(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plans")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	plan := `name: ThreeFund
+description: Bogleheads three fund portfolio
+allocations:
+  - symbol: VTI
+    desiredPercent: 0.5
+    equivalents: [FSKAX]
+  - {symbol: VEA, desiredPercent: 0.3}
+  - {symbol: VGIT, desiredPercent: 0.2}
+`
+	if err := ioutil.WriteFile(filepath.Join(dir, "three.yaml"), []byte(plan), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	plans, err := LoadPlansDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	threeFund, ok := plans["ThreeFund"]
+	if !ok {
+		t.Fatalf("expected the YAML plan to be loaded, got %v", plans)
+	}
+	if len(threeFund.Allocations) != 3 || threeFund.Allocations[0].DesiredPercent != .5 || !threeFund.Allocations[0].HasSymbol("FSKAX") {
+		t.Errorf("unexpected plan: %+v", threeFund)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "bad.yml"), []byte("allocations: [symbol: VTI"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadPlansDir(dir); err == nil {
+		t.Error("expected error for malformed YAML")
+	}
+}
+
+func TestRebalanceHybridSellsOnlyWhatCashCantCover(t *testing.T) {
+	newPlan := func() AllocationPlan {
+		return AllocationPlan{
+			Name: "Test",
+			Allocations: []*AssetAllocation{
+				{Symbol: "VTI", DesiredPercent: .5, CurrValue: 800 * money.Unit},
+				{Symbol: "VGIT", DesiredPercent: .5, CurrValue: 200 * money.Unit},
+			},
+		}
+	}
+
+	// Buy-only needs 600 of cash, 1000 covers it so nothing is sold and the
+	// 400 above that is invested on target too
+	plan := newPlan()
+	if err := plan.Rebalance(Hybrid, 1000*money.Unit); err != nil {
+		t.Fatal(err)
+	}
+	for _, trade := range plan.Trades() {
+		if !trade.IsBuy() {
+			t.Errorf("expected no sells, got %+v", trade)
+		}
+	}
+	if total := plan.GetDesiredTotalValue(); total != 2000*money.Unit {
+		t.Errorf("expected all the cash to be invested, got a total of %s", total)
+	}
+
+	// Rounding to the cent doesn't turn a holding worth a fraction of a cent
+	// more than its share into a sell
+	plan = AllocationPlan{
+		Name: "Test",
+		Allocations: []*AssetAllocation{
+			{Symbol: "VEA", DesiredPercent: 1.0 / 3, CurrValue: 0},
+			{Symbol: "VGIT", DesiredPercent: 1.0 / 3, CurrValue: 0},
+			{Symbol: "VTI", DesiredPercent: 1.0 / 3, CurrValue: money.MustParse("333.005")},
+		},
+	}
+	if err := plan.Rebalance(Hybrid, money.MustParse("666.01")); err != nil {
+		t.Fatal(err)
+	}
+	for _, aAllocation := range plan.Allocations {
+		if aAllocation.DesiredValue < aAllocation.CurrValue {
+			t.Errorf("expected no sells, %s went from %s to %s", aAllocation.Symbol, aAllocation.CurrValue, aAllocation.DesiredValue)
+		}
+	}
+
+	// 200 of cash leaves a total of 1200 so 200 of VTI has to be sold
+	plan = newPlan()
+	if err := plan.Rebalance(Hybrid, 200*money.Unit); err != nil {
+		t.Fatal(err)
+	}
+	trades := plan.Trades()
+	if len(trades) != 2 || trades[0].Symbol != "VTI" || trades[0].Amount != -200*money.Unit || trades[1].Amount != 400*money.Unit {
+		t.Errorf("unexpected trades: %+v", trades)
+	}
+}
+
+func TestContributeFillsMostUnderweightFirst(t *testing.T) {
+	plan := AllocationPlan{
+		Name: "Test",
+		Allocations: []*AssetAllocation{
+			{Symbol: "VTI", DesiredPercent: .5, CurrValue: 800 * money.Unit},
+			{Symbol: "VEA", DesiredPercent: .25, CurrValue: 100 * money.Unit},
+			{Symbol: "VGIT", DesiredPercent: .25, CurrValue: 300 * money.Unit},
+		},
+	}
+
+	if err := plan.Contribute(300 * money.Unit); err != nil {
+		t.Fatal(err)
+	}
+
+	// VEA is filled to VGIT's level (200 to 300), the other 100 goes to both
+	expected := map[string]money.Money{"VTI": money.MustParse("800"), "VEA": money.MustParse("350"), "VGIT": money.MustParse("350")}
+	for _, aAllocation := range plan.Allocations {
+		if aAllocation.DesiredValue != expected[aAllocation.Symbol] {
+			t.Errorf("%s: expected %s, got %s", aAllocation.Symbol, expected[aAllocation.Symbol], aAllocation.DesiredValue)
+		}
+	}
+}
+
+func TestWithdrawSellsMostOverweightFirst(t *testing.T) {
+	plan := AllocationPlan{
+		Name: "Test",
+		Allocations: []*AssetAllocation{
+			{Symbol: "VTI", DesiredPercent: .5, CurrValue: 800 * money.Unit},
+			{Symbol: "VEA", DesiredPercent: .25, CurrValue: 100 * money.Unit},
+			{Symbol: "VGIT", DesiredPercent: .25, CurrValue: 300 * money.Unit},
+		},
+	}
+
+	if err := plan.Withdraw(300 * money.Unit); err != nil {
+		t.Fatal(err)
+	}
+
+	// VTI is trimmed to VGIT's level (1600 to 1200), the other 100 comes from
+	// both, split in whole cents that add up to the 900 left exactly
+	expected := map[string]money.Money{"VTI": money.MustParse("533.33"), "VEA": money.MustParse("100"), "VGIT": money.MustParse("266.67")}
+	for _, aAllocation := range plan.Allocations {
+		if aAllocation.DesiredValue != expected[aAllocation.Symbol] {
+			t.Errorf("%s: expected %s, got %s", aAllocation.Symbol, expected[aAllocation.Symbol], aAllocation.DesiredValue)
+		}
+	}
+
+	if err := plan.Withdraw(5000 * money.Unit); err == nil {
+		t.Error("expected error withdrawing more than the plan holds")
+	}
+}
+
+func TestFlattenHierarchicalPlan(t *testing.T) {
+	plan := AllocationPlan{
+		Name: "Test",
+		Classes: []ClassTarget{
+			{Class: "Equity", Percent: .6, SubClasses: []SubClassTarget{
+				{SubClass: "Domestic", Percent: .5, Funds: []FundTarget{{Symbol: "VTI"}}},
+				{SubClass: "International", Percent: .5, Funds: []FundTarget{{Symbol: "VEA", Weight: 3}, {Symbol: "VWO", Weight: 1}}},
+			}},
+			{Class: "Bond", Percent: .4, Funds: []FundTarget{{Symbol: "VGIT"}}},
+		},
+	}
+
+	if err := plan.Flatten(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]float64{"VTI": .3, "VEA": .225, "VWO": .075, "VGIT": .4}
+	for _, aAllocation := range plan.Allocations {
+		if math.Abs(aAllocation.DesiredPercent-expected[aAllocation.Symbol]) > percentTolerance {
+			t.Errorf("%s: expected %f, got %f", aAllocation.Symbol, expected[aAllocation.Symbol], aAllocation.DesiredPercent)
+		}
+	}
+
+	// VGIT is a bond fund so it can't be listed under Equity
+	plan.Classes[0].SubClasses[0].Funds = []FundTarget{{Symbol: "VGIT"}}
+	if err := plan.Flatten(); err == nil {
+		t.Error("expected error for fund in the wrong class")
+	}
+
+	// VBIAX is partly bonds so it can be listed under Bond
+	plan.Classes[0].SubClasses[0].Funds = []FundTarget{{Symbol: "VTI"}}
+	plan.Classes[1].Funds = []FundTarget{{Symbol: "VBIAX"}}
+	if err := plan.Flatten(); err != nil {
+		t.Errorf("expected a blended fund to be allowed under a class it has components in: %v", err)
+	}
+}
+
+func TestDriftExceeds(t *testing.T) {
+	tests := []struct {
+		name     string
+		drift    Drift
+		points   float64
+		relative float64
+		expected bool
+	}{
+		{name: "within both", drift: Drift{CurrPercent: .52, DesiredPercent: .5}, points: .05, relative: .25},
+		{name: "over points", drift: Drift{CurrPercent: .44, DesiredPercent: .5}, points: .05, relative: .25, expected: true},
+		{name: "over relative", drift: Drift{CurrPercent: .07, DesiredPercent: .1}, points: .05, relative: .25, expected: true},
+		{name: "0 points disabled", drift: Drift{CurrPercent: .44, DesiredPercent: .5}, relative: .25},
+		{name: "0 relative disabled", drift: Drift{CurrPercent: .07, DesiredPercent: .1}, points: .05},
+		{name: "both disabled", drift: Drift{CurrPercent: 1, DesiredPercent: 0}},
+		{name: "held without a target", drift: Drift{CurrPercent: .01, DesiredPercent: 0}, points: .05, relative: .25, expected: true},
+		{name: "neither held nor targeted", drift: Drift{}, points: .05, relative: .25},
+	}
+
+	for _, test := range tests {
+		if exceeds := test.drift.Exceeds(test.points, test.relative); exceeds != test.expected {
+			t.Errorf("%s: expected %t, got %t", test.name, test.expected, exceeds)
+		}
+	}
+}
+
+func TestSymbolAndClassDrifts(t *testing.T) {
+	plan := AllocationPlan{
+		Name: "Test",
+		Allocations: []*AssetAllocation{
+			{Symbol: "VTI", DesiredPercent: .6, CurrValue: 700 * money.Unit},
+			{Symbol: "VGIT", DesiredPercent: .4, CurrValue: 290 * money.Unit},
+			{Symbol: "VNQ", DesiredPercent: 0, CurrValue: 10 * money.Unit},
+		},
+	}
+
+	// VTI is 10 points over, VGIT 27.5% under and VNQ isn't targeted at all
+	expected := map[string]bool{"VTI": true, "VGIT": true, "VNQ": true}
+	drifts := plan.SymbolDrifts()
+	if len(drifts) != len(expected) {
+		t.Fatalf("expected %d symbol drifts, got %+v", len(expected), drifts)
+	}
+	for _, drift := range drifts {
+		if exceeds := drift.Exceeds(.05, .25); exceeds != expected[drift.Name] {
+			t.Errorf("%s: expected %t, got %t", drift.Name, expected[drift.Name], exceeds)
+		}
+	}
+
+	// Only the points threshold, VGIT's 11 points are over and VNQ's 1 isn't
+	expected = map[string]bool{"VTI": true, "VGIT": true, "VNQ": false}
+	for _, drift := range drifts {
+		if exceeds := drift.Exceeds(.05, 0); exceeds != expected[drift.Name] {
+			t.Errorf("%s: expected %t, got %t", drift.Name, expected[drift.Name], exceeds)
+		}
+	}
+
+	// RealEstate is held without a target, the class drifts add up the same way
+	expectedClasses := map[string]bool{"Equity": true, "Bond": true, "RealEstate": true}
+	classDrifts := plan.ClassDrifts()
+	if len(classDrifts) != len(expectedClasses) {
+		t.Fatalf("expected %d class drifts, got %+v", len(expectedClasses), classDrifts)
+	}
+	for _, drift := range classDrifts {
+		if exceeds := drift.Exceeds(.05, .25); exceeds != expectedClasses[drift.Name] {
+			t.Errorf("%s: expected %t, got %t", drift.Name, expectedClasses[drift.Name], exceeds)
+		}
+		if exceeds := drift.Exceeds(0, 0); exceeds {
+			t.Errorf("%s: expected thresholds of 0 to never be exceeded", drift.Name)
+		}
+	}
+}
+
+func TestClassDriftsLookThroughBlendedFunds(t *testing.T) {
+	plan := AllocationPlan{
+		Name: "Test",
+		Allocations: []*AssetAllocation{
+			{Symbol: "VBIAX", DesiredPercent: .5, CurrValue: 500 * money.Unit},
+			{Symbol: "VGIT", DesiredPercent: .5, CurrValue: 500 * money.Unit},
+		},
+	}
+
+	// VBIAX is 60% VTI and 40% bonds
+	expected := map[string]float64{"Equity": .3, "Bond": .7}
+	drifts := plan.ClassDrifts()
+	if len(drifts) != len(expected) {
+		t.Fatalf("expected %d class drifts, got %d", len(expected), len(drifts))
+	}
+	for _, drift := range drifts {
+		if math.Abs(drift.CurrPercent-expected[drift.Name]) > percentTolerance {
+			t.Errorf("%s: expected %f, got %f", drift.Name, expected[drift.Name], drift.CurrPercent)
+		}
+	}
+}
+
+func TestLevelDriftsLookThroughBlendedFunds(t *testing.T) {
+	flat := AllocationPlan{
+		Name: "Test",
+		Allocations: []*AssetAllocation{
+			{Symbol: "VBIAX", DesiredPercent: .5, CurrValue: 500 * money.Unit},
+			{Symbol: "VGIT", DesiredPercent: .5, CurrValue: 500 * money.Unit},
+		},
+	}
+
+	hierarchical := AllocationPlan{
+		Name: "Test",
+		Classes: []ClassTarget{
+			{Class: "Equity", Percent: .5, Funds: []FundTarget{{Symbol: "VBIAX"}}},
+			{Class: "Bond", Percent: .5, Funds: []FundTarget{{Symbol: "VGIT"}}},
+		},
+	}
+	if err := hierarchical.Flatten(); err != nil {
+		t.Fatal(err)
+	}
+	hierarchical.Allocations[0].CurrValue = 500 * money.Unit
+	hierarchical.Allocations[1].CurrValue = 500 * money.Unit
+
+	// VBIAX is 60% VTI and 40% bonds, its bonds count towards Bond either way
+	tests := []struct {
+		plan     AllocationPlan
+		expected []Drift
+	}{
+		{flat, []Drift{
+			{Name: "Equity", Level: 0, CurrPercent: .3},
+			{Name: "Domestic", Level: 1, CurrPercent: .3},
+			{Name: "VBIAX", Level: 2, CurrPercent: .3},
+			{Name: "Bond", Level: 0, CurrPercent: .7},
+			{Name: "VBIAX", Level: 1, CurrPercent: .2},
+			{Name: "Medium Term Treasury", Level: 1, CurrPercent: .5},
+			{Name: "VGIT", Level: 2, CurrPercent: .5},
+		}},
+		{hierarchical, []Drift{
+			{Name: "Equity", Level: 0, CurrPercent: .3},
+			{Name: "VBIAX", Level: 1, CurrPercent: .3},
+			{Name: "Bond", Level: 0, CurrPercent: .7},
+			{Name: "VGIT", Level: 1, CurrPercent: .5},
+		}},
+	}
+	for _, test := range tests {
+		drifts := test.plan.LevelDrifts()
+		if len(drifts) != len(test.expected) {
+			t.Errorf("expected %+v, got %+v", test.expected, drifts)
+			continue
+		}
+		for idx, drift := range drifts {
+			expected := test.expected[idx]
+			if drift.Name != expected.Name || drift.Level != expected.Level || math.Abs(drift.CurrPercent-expected.CurrPercent) > percentTolerance {
+				t.Errorf("expected %+v, got %+v", expected, drift)
+			}
+		}
+	}
+}
+
+func TestFullRebalanceInvestsCashAboveTarget(t *testing.T) {
+	plan := AllocationPlan{
+		Name:        "Test",
+		CashReserve: 100 * money.Unit,
+		Allocations: []*AssetAllocation{
+			{Symbol: "VTI", DesiredPercent: .5, CurrValue: 600 * money.Unit},
+			{Symbol: "VGIT", DesiredPercent: .5, CurrValue: 200 * money.Unit},
+		},
+	}
+	plan.SetCash(300 * money.Unit)
+
+	if err := plan.Rebalance(FullRebalance, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// 200 of the 300 cash is above the reserve and gets invested
+	expected := map[string]money.Money{"VTI": money.MustParse("500"), "VGIT": money.MustParse("500")}
+	for _, aAllocation := range plan.Allocations {
+		if aAllocation.DesiredValue != expected[aAllocation.Symbol] {
+			t.Errorf("%s: expected %s, got %s", aAllocation.Symbol, expected[aAllocation.Symbol], aAllocation.DesiredValue)
+		}
+	}
+	if plan.CashRequired() != 0 {
+		t.Errorf("expected no cash required, got %s", plan.CashRequired())
+	}
+}
+
+func TestOrdersTradeTheHeldEquivalent(t *testing.T) {
+	plan := AllocationPlan{
+		Name: "Test",
+		Allocations: []*AssetAllocation{
+			{Symbol: "VTI", Equivalents: []string{"FSKAX"}, CurrValue: 2000 * money.Unit, DesiredValue: 1000 * money.Unit},
+			{Symbol: "VXUS", Equivalents: []string{"FTIHX"}, CurrValue: 500 * money.Unit, DesiredValue: 1500 * money.Unit},
+		},
+	}
+	prices := map[string]money.Money{"VTI": 200 * money.Unit, "FSKAX": 100 * money.Unit, "VXUS": 60 * money.Unit, "FTIHX": 50 * money.Unit}
+	quantities := map[string]float64{"FSKAX": 20, "FTIHX": 10}
+
+	orderList := plan.Orders(prices, quantities, 0)
+	if len(orderList.Unpriced) != 0 {
+		t.Errorf("expected every trade to be priced, got %+v", orderList.Unpriced)
+	}
+
+	// Only the equivalents are held, so they're what is sold and bought
+	expected := []Order{
+		{Symbol: "FSKAX", Shares: -10, Price: 100 * money.Unit},
+		{Symbol: "FTIHX", Shares: 20, Price: 50 * money.Unit},
+	}
+	if len(orderList.Orders) != len(expected) {
+		t.Fatalf("expected %+v, got %+v", expected, orderList.Orders)
+	}
+	for idx, order := range orderList.Orders {
+		if order != expected[idx] {
+			t.Errorf("expected %+v, got %+v", expected[idx], order)
+		}
+	}
+}
+
+func TestLocatePlacesAssetsByTaxType(t *testing.T) {
+	plan := AllocationPlan{
+		Name: "Test",
+		Allocations: []*AssetAllocation{
+			{Symbol: "VTI", DesiredPercent: .5, CurrValue: 1000 * money.Unit},
+			{Symbol: "VGIT", DesiredPercent: .5, CurrValue: 1000 * money.Unit},
+		},
+	}
+	accountValues := map[string]map[string]money.Money{
+		"Brokerage": {"VGIT": 1000 * money.Unit},
+		"IRA":       {"VTI": 1000 * money.Unit},
+	}
+
+	// Brokerage has no tax type so it's taxable, bonds belong in the IRA
+	trades, unplaced, err := plan.Locate(accountValues, nil, map[string]portfolio.TaxType{"IRA": portfolio.Traditional})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unplaced) != 0 {
+		t.Errorf("expected everything to be placed, got %v", unplaced)
+	}
+
+	expected := []AccountTrade{
+		{Account: "Brokerage", Symbol: "VTI", Amount: 1000 * money.Unit},
+		{Account: "Brokerage", Symbol: "VGIT", Amount: -1000 * money.Unit},
+		{Account: "IRA", Symbol: "VTI", Amount: -1000 * money.Unit},
+		{Account: "IRA", Symbol: "VGIT", Amount: 1000 * money.Unit},
+	}
+	if len(trades) != len(expected) {
+		t.Fatalf("expected %+v, got %+v", expected, trades)
+	}
+	for _, trade := range expected {
+		found := false
+		for _, got := range trades {
+			found = found || got == trade
+		}
+		if !found {
+			t.Errorf("expected %+v in %+v", trade, trades)
+		}
+	}
+}
+
+func TestLocateInvestsEachAccountsCash(t *testing.T) {
+	newPlan := func() AllocationPlan {
+		plan := AllocationPlan{
+			Name: "Test",
+			Allocations: []*AssetAllocation{
+				{Symbol: "VTI", DesiredPercent: .5, CurrValue: 1000 * money.Unit},
+				{Symbol: "VGIT", DesiredPercent: .5, CurrValue: 1000 * money.Unit},
+			},
+		}
+		plan.SetCash(500 * money.Unit)
+		return plan
+	}
+	accountValues := map[string]map[string]money.Money{
+		"Brokerage": {"VGIT": 1000 * money.Unit, "SPAXX": 500 * money.Unit},
+		"IRA":       {"VTI": 1000 * money.Unit},
+	}
+	taxTypes := map[string]portfolio.TaxType{"IRA": portfolio.Traditional}
+
+	// The brokerage cash is invested there, the IRA fills up with bonds first
+	plan := newPlan()
+	trades, unplaced, err := plan.Locate(accountValues, map[string]money.Money{"Brokerage": 500 * money.Unit}, taxTypes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unplaced) != 0 {
+		t.Errorf("expected everything to be placed, got %v", unplaced)
+	}
+	bought := map[string]money.Money{}
+	for _, trade := range trades {
+		bought[trade.Account] += trade.Amount
+	}
+	if bought["Brokerage"] != 500*money.Unit || bought["IRA"] != 0 {
+		t.Errorf("expected only the brokerage cash to be invested, got %+v", trades)
+	}
+
+	// Without the cash in an account the household target doesn't fit and the rest is reported
+	plan = newPlan()
+	_, unplaced, err = plan.Locate(accountValues, nil, taxTypes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unplaced) != 1 || unplaced["VTI"] != 500*money.Unit {
+		t.Errorf("expected 500 of VTI to be unplaced, got %v", unplaced)
+	}
+}
+
+func TestHoldCutsTheBuysASellPaidFor(t *testing.T) {
+	plan := AllocationPlan{
+		Name: "Test",
+		Allocations: []*AssetAllocation{
+			{Symbol: "VTI", DesiredPercent: .5, CurrValue: 1500 * money.Unit},
+			{Symbol: "VEA", DesiredPercent: .25, CurrValue: 200 * money.Unit},
+			{Symbol: "VGIT", DesiredPercent: .25, CurrValue: 300 * money.Unit},
+		},
+	}
+	if err := plan.Rebalance(FullRebalance, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if cut := plan.Hold("VTI"); cut != 500*money.Unit {
+		t.Errorf("expected the buys to be cut by 500, got %s", cut)
+	}
+
+	// The 300 VEA and 200 VGIT buys are all gone with the VTI sell
+	if trades := plan.Trades(); len(trades) != 0 {
+		t.Errorf("expected no trades, got %+v", trades)
+	}
+	if plan.CashRequired() != 0 {
+		t.Errorf("expected no cash required, got %s", plan.CashRequired())
+	}
+
+	// Holding a buy leaves the other trades alone
+	if err := plan.Rebalance(FullRebalance, 0); err != nil {
+		t.Fatal(err)
+	}
+	if cut := plan.Hold("VEA"); cut != 0 {
+		t.Errorf("expected nothing cut, got %s", cut)
+	}
+	if trades := plan.Trades(); len(trades) != 2 {
+		t.Errorf("expected the VTI sell and VGIT buy, got %+v", trades)
+	}
+}
+
+func TestBuyOnlyInvestsAvailableCash(t *testing.T) {
+	plan := AllocationPlan{
+		Name: "Test",
+		Allocations: []*AssetAllocation{
+			{Symbol: "VTI", DesiredPercent: .5, CurrValue: 600 * money.Unit},
+			{Symbol: "VGIT", DesiredPercent: .5, CurrValue: 400 * money.Unit},
+		},
+	}
+	plan.SetCash(1000 * money.Unit)
+
+	if err := plan.Rebalance(BuyOnly, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// Putting VTI on target only takes 200, the rest of the cash is invested on target
+	expected := map[string]money.Money{"VTI": 1000 * money.Unit, "VGIT": 1000 * money.Unit}
+	for _, aAllocation := range plan.Allocations {
+		if aAllocation.DesiredValue != expected[aAllocation.Symbol] {
+			t.Errorf("%s: expected %s, got %s", aAllocation.Symbol, expected[aAllocation.Symbol], aAllocation.DesiredValue)
+		}
+	}
+	if plan.CashRequired() != 0 {
+		t.Errorf("expected no cash required, got %s", plan.CashRequired())
+	}
+}
+
+func TestBuyOnlyKeepsAssetsWithoutATarget(t *testing.T) {
+	plan := AllocationPlan{
+		Name: "Test",
+		Allocations: []*AssetAllocation{
+			{Symbol: "VTI", DesiredPercent: .5, CurrValue: 600 * money.Unit},
+			{Symbol: "VGIT", DesiredPercent: .5, CurrValue: 200 * money.Unit},
+			{Symbol: "VNQ", DesiredPercent: 0, CurrValue: 300 * money.Unit},
+		},
+	}
+
+	if err := plan.Rebalance(BuyOnly, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// VNQ is the furthest off target but can't size the plan, VTI does
+	expected := map[string]money.Money{"VTI": 600 * money.Unit, "VGIT": 600 * money.Unit, "VNQ": 300 * money.Unit}
+	for _, aAllocation := range plan.Allocations {
+		if aAllocation.DesiredValue != expected[aAllocation.Symbol] {
+			t.Errorf("%s: expected %s, got %s", aAllocation.Symbol, expected[aAllocation.Symbol], aAllocation.DesiredValue)
+		}
+	}
+}
+
+func TestOrdersRoundSharesAndSpendLeftoverCash(t *testing.T) {
+	plan := AllocationPlan{
+		Name: "Test",
+		Allocations: []*AssetAllocation{
+			{Symbol: "VTI", CurrValue: 0, DesiredValue: 1000 * money.Unit},
+			{Symbol: "VGIT", CurrValue: 0, DesiredValue: 500 * money.Unit},
+			{Symbol: "VXUS", CurrValue: 200 * money.Unit, DesiredValue: 0},
+		},
+	}
+	prices := map[string]money.Money{"VTI": 300 * money.Unit, "VGIT": 60 * money.Unit, "VXUS": 80 * money.Unit}
+	quantities := map[string]float64{"VXUS": 2.5}
+
+	orderList := plan.Orders(prices, quantities, 0)
+
+	// Buys round down, the VXUS sell rounds up to 3 shares but only 2.5 are
+	// held, and the 120 left over buys one more VGIT since VTI costs too much
+	expected := []Order{
+		{Symbol: "VXUS", Shares: -2.5, Price: 80 * money.Unit},
+		{Symbol: "VTI", Shares: 3, Price: 300 * money.Unit},
+		{Symbol: "VGIT", Shares: 9, Price: 60 * money.Unit},
+	}
+	if len(orderList.Orders) != len(expected) {
+		t.Fatalf("expected %+v, got %+v", expected, orderList.Orders)
+	}
+	for idx, order := range orderList.Orders {
+		if order != expected[idx] {
+			t.Errorf("expected %+v, got %+v", expected[idx], order)
+		}
+	}
+	if orderList.LeftoverCash != 60*money.Unit {
+		t.Errorf("expected 60 left over, got %s", orderList.LeftoverCash)
+	}
+
+	// Fractional shares round to the precision
+	orderList = plan.Orders(prices, quantities, 2)
+	for _, order := range orderList.Orders {
+		if order.Symbol == "VTI" && math.Abs(order.Shares-3.33) > 1e-9 {
+			t.Errorf("expected 3.33 VTI shares, got %v", order.Shares)
+		}
+	}
+}
+
+func TestRebalanceBands(t *testing.T) {
+	newPlan := func() AllocationPlan {
+		plan := AllocationPlan{
+			Name: "Test",
+			Allocations: []*AssetAllocation{
+				{Symbol: "VTI", DesiredPercent: .5, CurrValue: 600 * money.Unit},
+				{Symbol: "VGIT", DesiredPercent: .3, CurrValue: 200 * money.Unit},
+				{Symbol: "VEA", DesiredPercent: .2, CurrValue: 200 * money.Unit},
+			},
+		}
+		// The relative band of VTI is 12.5 points, the absolute 5 points is tighter
+		plan.SetDefaultBands(.05, .25)
+		return plan
+	}
+
+	plan := newPlan()
+	breaches := plan.Breaches()
+	if len(breaches) != 2 || breaches[0].Symbol != "VTI" || breaches[1].Symbol != "VGIT" {
+		t.Errorf("expected VTI and VGIT to breach their bands, got %+v", breaches)
+	}
+
+	tests := []struct {
+		bandTarget BandTarget
+		expected   map[string]money.Money
+	}{
+		{ToTarget, map[string]money.Money{"VTI": 500 * money.Unit, "VGIT": 300 * money.Unit, "VEA": 200 * money.Unit}},
+		{ToBandEdge, map[string]money.Money{"VTI": 550 * money.Unit, "VGIT": 250 * money.Unit, "VEA": 200 * money.Unit}},
+	}
+	for _, test := range tests {
+		plan := newPlan()
+		if err := plan.RebalanceBands(test.bandTarget); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, aAllocation := range plan.Allocations {
+			if aAllocation.DesiredValue != test.expected[aAllocation.Symbol] {
+				t.Errorf("%s %s: expected %s, got %s", test.bandTarget, aAllocation.Symbol, test.expected[aAllocation.Symbol], aAllocation.DesiredValue)
+			}
+		}
+	}
+}
+
+func TestOrdersNeverSpendMoreThanTheTrades(t *testing.T) {
+	plan := AllocationPlan{
+		Name: "Test",
+		Allocations: []*AssetAllocation{
+			{Symbol: "VTI", CurrValue: 20000 * money.Unit, DesiredValue: money.MustParse("13840")}, // sells 6160
+			{Symbol: "TLT", CurrValue: 0, DesiredValue: money.MustParse("6380")},
+			{Symbol: "PENNY", CurrValue: 0, DesiredValue: money.MustParse("0.25")},
+		},
+	}
+	prices := map[string]money.Money{"VTI": 200 * money.Unit, "TLT": 90 * money.Unit, "PENNY": money.MustParse("0.40")}
+	quantities := map[string]float64{"VTI": 100}
+
+	// A negative precision is whole shares, not lots of 100
+	for _, precision := range []int{-2, 0, 2} {
+		orderList := plan.Orders(prices, quantities, precision)
+
+		spent := money.Money(0)
+		for _, order := range orderList.Orders {
+			spent += order.Amount()
+			if order.Symbol == "VTI" && order.Shares < -31 {
+				t.Errorf("precision %d: expected to sell at most 31 VTI, got %v", precision, -order.Shares)
+			}
+		}
+		if budget := money.MustParse("220.25"); spent > budget || orderList.LeftoverCash < 0 {
+			t.Errorf("precision %d: spent %s of %s, %s left over", precision, spent, budget, orderList.LeftoverCash)
+		}
+	}
+}
+
+func TestValuesAfterIncludeUnpricedTrades(t *testing.T) {
+	plan := AllocationPlan{
+		Name: "Test",
+		Allocations: []*AssetAllocation{
+			{Symbol: "VTI", CurrValue: 1000 * money.Unit, DesiredValue: 500 * money.Unit},
+			{Symbol: "GLD", CurrValue: 0, DesiredValue: 500 * money.Unit},
+		},
+	}
+	prices := map[string]money.Money{"VTI": 100 * money.Unit}
+
+	orderList := plan.Orders(prices, map[string]float64{"VTI": 10}, 0)
+	if len(orderList.Unpriced) != 1 {
+		t.Fatalf("expected the GLD buy to be unpriced, got %+v", orderList)
+	}
+
+	values := plan.ValuesAfter(orderList)
+	if values["VTI"] != 500*money.Unit || values["GLD"] != 500*money.Unit {
+		t.Errorf("expected 500 of each, got %v", values)
+	}
+}
@@ -0,0 +1,107 @@
+package allocations
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/samkreter/portfoli/pkg/money"
+)
+
+const (
+	// bandTolerance keeps float noise from counting as a breach
+	bandTolerance = 1e-9
+)
+
+// BandTarget is where an asset breaching its band is traded back to
+type BandTarget string
+
+const (
+	// ToTarget trades breaching assets all the way back to their target
+	ToTarget = BandTarget("target")
+	// ToBandEdge trades breaching assets only back to the nearest edge of their band
+	ToBandEdge = BandTarget("edge")
+)
+
+// Band returns how far, in percent of the plan, the asset may drift from its
+// target before it needs rebalancing. The absolute band is in percentage
+// points (.05 is ±5 points) and the relative band is a fraction of the target
+// (.25 is ±25% of the target). With both set the tighter one applies and with
+// neither set the band is 0, so any drift is a breach.
+func (a AssetAllocation) Band() float64 {
+	relative := a.RelativeBand * a.DesiredPercent
+
+	switch {
+	case a.AbsoluteBand > 0 && a.RelativeBand > 0:
+		return math.Min(a.AbsoluteBand, relative)
+	case a.AbsoluteBand > 0:
+		return a.AbsoluteBand
+	default:
+		return relative
+	}
+}
+
+// SetDefaultBands sets the bands of every asset that has none configured
+func (plan *AllocationPlan) SetDefaultBands(absolute, relative float64) {
+	for _, aAllocation := range plan.Allocations {
+		if aAllocation.AbsoluteBand == 0 && aAllocation.RelativeBand == 0 {
+			aAllocation.AbsoluteBand = absolute
+			aAllocation.RelativeBand = relative
+		}
+	}
+}
+
+// Breaches returns the assets whose current percent of the plan is outside their band
+func (plan AllocationPlan) Breaches() []*AssetAllocation {
+	total := plan.GetCurrTotalVal()
+
+	breaches := []*AssetAllocation{}
+	for _, aAllocation := range plan.Allocations {
+		if aAllocation.isBreaching(total) {
+			breaches = append(breaches, aAllocation)
+		}
+	}
+
+	return breaches
+}
+
+// RebalanceBands updates the desired values of the plan so only assets
+// breaching their band are traded, back to their target or band edge. Assets
+// inside their band keep their current value, so the trades don't have to net
+// to 0 and the difference is cash in or out of the plan.
+func (plan *AllocationPlan) RebalanceBands(bandTarget BandTarget) error {
+	if bandTarget != ToTarget && bandTarget != ToBandEdge {
+		return fmt.Errorf("invalid band target: %s", bandTarget)
+	}
+
+	plan.computeCurrPercents()
+	total := plan.GetCurrTotalVal()
+
+	for _, aAllocation := range plan.Allocations {
+		aAllocation.DesiredValue = aAllocation.CurrValue
+		if !aAllocation.isBreaching(total) {
+			continue
+		}
+
+		percent := aAllocation.DesiredPercent
+		if bandTarget == ToBandEdge {
+			if aAllocation.CurrValue.Ratio(total) > aAllocation.DesiredPercent {
+				percent += aAllocation.Band()
+			} else {
+				percent -= aAllocation.Band()
+			}
+		}
+
+		aAllocation.DesiredValue = total.Mul(percent).Round()
+	}
+
+	return plan.Validate()
+}
+
+func (a AssetAllocation) isBreaching(total money.Money) bool {
+	if total == 0 {
+		return false
+	}
+
+	drift := math.Abs(a.CurrValue.Ratio(total) - a.DesiredPercent)
+	return drift > a.Band()+bandTolerance
+}
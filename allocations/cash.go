@@ -0,0 +1,26 @@
+package allocations
+
+import "github.com/samkreter/portfoli/pkg/money"
+
+// SetCash sets the cash held outside of the plan's assets, e.g. in money market funds
+func (plan *AllocationPlan) SetCash(cash money.Money) {
+	plan.Cash = cash
+}
+
+// CashTarget returns the cash the plan keeps uninvested, its cash percent of
+// the whole portfolio, assets and cash, plus its fixed reserve
+func (plan AllocationPlan) CashTarget() money.Money {
+	return plan.CashReserve + (plan.GetCurrTotalVal() + plan.Cash).Mul(plan.CashPercent).Round()
+}
+
+// AvailableCash returns the held cash above the cash target, negative when
+// the plan has to raise cash to reach its target
+func (plan AllocationPlan) AvailableCash() money.Money {
+	return plan.Cash - plan.CashTarget()
+}
+
+// CashRequired returns the cash needed on top of the available cash to reach
+// the desired values, negative when the trades free up cash
+func (plan AllocationPlan) CashRequired() money.Money {
+	return plan.GetDesiredTotalValue() - plan.GetCurrTotalVal() - plan.AvailableCash()
+}
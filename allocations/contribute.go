@@ -0,0 +1,61 @@
+package allocations
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/samkreter/portfoli/pkg/money"
+)
+
+// Contribute updates the desired values of the plan to invest cash without
+// selling. The cash is water-filled into the most underweight assets first
+// so the plan ends up as close to its targets as buying alone allows.
+func (plan *AllocationPlan) Contribute(cash money.Money) error {
+	if cash < 0 {
+		return errors.New("contribution must not be negative")
+	}
+
+	plan.computeCurrPercents()
+	currTotal := plan.GetCurrTotalVal()
+
+	// Raising the fill level to a total of level buys every asset whose
+	// current value is below DesiredPercent*level up to that value
+	plan.computeDesiredValues(plan.fillLevel(cash))
+	for _, aAllocation := range plan.Allocations {
+		aAllocation.DesiredValue = money.Max(aAllocation.DesiredValue, aAllocation.CurrValue)
+	}
+	plan.settle(currTotal + cash)
+
+	return plan.Validate()
+}
+
+// fillLevel returns the plan total at which buying every asset below its
+// target up to that total uses exactly cash
+func (plan AllocationPlan) fillLevel(cash money.Money) money.Money {
+	funded := []*AssetAllocation{}
+	for _, aAllocation := range plan.Allocations {
+		if aAllocation.DesiredPercent > 0 {
+			funded = append(funded, aAllocation)
+		}
+	}
+
+	// Most underweight first, the order assets start receiving cash
+	sort.Slice(funded, func(i, j int) bool {
+		return funded[i].CurrValue.Div(funded[i].DesiredPercent) < funded[j].CurrValue.Div(funded[j].DesiredPercent)
+	})
+
+	level := money.Money(0)
+	currSum, percentSum := money.Money(0), 0.0
+	for idx, aAllocation := range funded {
+		currSum += aAllocation.CurrValue
+		percentSum += aAllocation.DesiredPercent
+		level = (cash + currSum).Div(percentSum)
+
+		// Stop once the level doesn't reach the next asset
+		if idx+1 < len(funded) && level <= funded[idx+1].CurrValue.Div(funded[idx+1].DesiredPercent) {
+			break
+		}
+	}
+
+	return level
+}
@@ -1,8 +1,16 @@
 package allocations
 
+// builtinAllocations are the allocation plans shipped with portfoli. User plan
+// files with the same name override them.
+var builtinAllocations = map[string]func() AllocationPlan{
+	"AllWeather": getAllWeatherAllocation,
+	"Swensen":    getSwensenAllocation,
+}
+
 func getAllWeatherAllocation() AllocationPlan {
 	return AllocationPlan{
-		Name: "AllWeather",
+		Name:        "AllWeather",
+		Description: "Ray Dalio's All Weather portfolio",
 		Allocations: []*AssetAllocation{
 			{
 				Symbol:         "VTI",
@@ -30,7 +38,8 @@ func getAllWeatherAllocation() AllocationPlan {
 
 func getSwensenAllocation() AllocationPlan {
 	return AllocationPlan{
-		Name: "Swensen",
+		Name:        "Swensen",
+		Description: "David Swensen's lazy portfolio from Unconventional Success",
 		Allocations: []*AssetAllocation{
 			{
 				Symbol:         "VTI",
@@ -0,0 +1,85 @@
+package allocations
+
+import (
+	"math"
+
+	"github.com/samkreter/portfoli/asset"
+)
+
+// Drift compares the current percent of the plan held in a symbol or asset
+// class with its target
+type Drift struct {
+	Name string
+	// Level is the depth in the plan's hierarchy, 0 for classes with subclasses
+	// and funds each one level below their parent
+	Level          int
+	CurrPercent    float64
+	DesiredPercent float64
+}
+
+// Points returns the drift in percentage points of the plan, positive when overweight
+func (d Drift) Points() float64 {
+	return d.CurrPercent - d.DesiredPercent
+}
+
+// Relative returns the drift as a fraction of the target, +Inf for holdings without a target
+func (d Drift) Relative() float64 {
+	if d.DesiredPercent == 0 {
+		if d.CurrPercent == 0 {
+			return 0
+		}
+		return math.Inf(1)
+	}
+
+	return d.Points() / d.DesiredPercent
+}
+
+// Exceeds reports whether the drift is over either threshold. A threshold of 0 is not checked.
+func (d Drift) Exceeds(pointsThreshold, relativeThreshold float64) bool {
+	if pointsThreshold > 0 && math.Abs(d.Points()) > pointsThreshold {
+		return true
+	}
+
+	return relativeThreshold > 0 && math.Abs(d.Relative()) > relativeThreshold
+}
+
+// SymbolDrifts returns the drift of every asset in the plan
+func (plan *AllocationPlan) SymbolDrifts() []Drift {
+	plan.computeCurrPercents()
+
+	drifts := []Drift{}
+	for _, aAllocation := range plan.Allocations {
+		drifts = append(drifts, Drift{
+			Name:           aAllocation.Symbol,
+			CurrPercent:    aAllocation.CurrPercent,
+			DesiredPercent: aAllocation.DesiredPercent,
+		})
+	}
+
+	return drifts
+}
+
+// ClassDrifts returns the drift of every asset class the plan holds or targets,
+// blended funds count towards the classes of their components
+func (plan *AllocationPlan) ClassDrifts() []Drift {
+	plan.computeCurrPercents()
+
+	classWeights := plan.classWeights()
+
+	drifts := []Drift{}
+	for _, class := range asset.GetAssetClasses() {
+		drift := Drift{Name: string(class)}
+
+		for _, aAllocation := range plan.Allocations {
+			weight := classWeights[aAllocation.Symbol][class]
+			drift.CurrPercent += weight * aAllocation.CurrPercent
+			drift.DesiredPercent += weight * aAllocation.DesiredPercent
+		}
+
+		if drift.CurrPercent != 0 || drift.DesiredPercent != 0 {
+			drifts = append(drifts, drift)
+		}
+	}
+
+	return drifts
+}
@@ -0,0 +1,325 @@
+package allocations
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/samkreter/portfoli/asset"
+)
+
+const (
+	// unclassified groups the assets of a flat plan missing from the asset registry
+	unclassified = "Unclassified"
+)
+
+// ClassTarget is the target for an asset class, split further into either
+// subclasses or directly into funds
+type ClassTarget struct {
+	Class      asset.Class      `json:"class"`
+	Percent    float64          `json:"percent"`
+	SubClasses []SubClassTarget `json:"subClasses,omitempty"`
+	Funds      []FundTarget     `json:"funds,omitempty"`
+}
+
+// SubClassTarget is the target for a subclass as a percent of its class
+type SubClassTarget struct {
+	SubClass asset.SubClass `json:"subClass"`
+	Percent  float64        `json:"percent"`
+	Funds    []FundTarget   `json:"funds"`
+}
+
+// FundTarget is a fund in a class or subclass. The percent of the class or
+// subclass is split between its funds by weight, equally when no weights are set.
+type FundTarget struct {
+	Symbol string  `json:"symbol"`
+	Weight float64 `json:"weight,omitempty"`
+
+	Equivalents    []string          `json:"equivalents,omitempty"`
+	AccountSymbols map[string]string `json:"accountSymbols,omitempty"`
+}
+
+// Flatten sets the plan's allocations to the symbol targets defined by its class hierarchy
+func (plan *AllocationPlan) Flatten() error {
+	if err := plan.validateClasses(); err != nil {
+		return err
+	}
+
+	plan.Allocations = []*AssetAllocation{}
+	for _, classTarget := range plan.Classes {
+		plan.addFunds(classTarget.Funds, classTarget.Percent)
+		for _, subClassTarget := range classTarget.SubClasses {
+			plan.addFunds(subClassTarget.Funds, classTarget.Percent*subClassTarget.Percent)
+		}
+	}
+
+	return plan.Validate()
+}
+
+func (plan *AllocationPlan) addFunds(funds []FundTarget, percent float64) {
+	totalWeight := 0.0
+	for _, fund := range funds {
+		totalWeight += fundWeight(fund)
+	}
+
+	for _, fund := range funds {
+		plan.Allocations = append(plan.Allocations, &AssetAllocation{
+			Symbol:         fund.Symbol,
+			DesiredPercent: percent * fundWeight(fund) / totalWeight,
+			Equivalents:    fund.Equivalents,
+			AccountSymbols: fund.AccountSymbols,
+		})
+	}
+}
+
+func fundWeight(fund FundTarget) float64 {
+	if fund.Weight == 0 {
+		return 1
+	}
+	return fund.Weight
+}
+
+// validateClasses ensures every level of the hierarchy adds up and funds are
+// in the class the asset registry has them in
+func (plan AllocationPlan) validateClasses() error {
+	if len(plan.Classes) == 0 {
+		return errors.New("allocation plan has no classes")
+	}
+
+	classTotal := 0.0
+	for _, classTarget := range plan.Classes {
+		classTotal += classTarget.Percent
+
+		if len(classTarget.SubClasses) == 0 && len(classTarget.Funds) == 0 {
+			return fmt.Errorf("class %q has no subclasses or funds", classTarget.Class)
+		}
+
+		if len(classTarget.SubClasses) > 0 && len(classTarget.Funds) > 0 {
+			return fmt.Errorf("class %q has both subclasses and funds", classTarget.Class)
+		}
+
+		if err := validateFunds(string(classTarget.Class), classTarget.Class, classTarget.Funds); err != nil {
+			return err
+		}
+		if len(classTarget.Funds) > 0 {
+			continue
+		}
+
+		subClassTotal := 0.0
+		for _, subClassTarget := range classTarget.SubClasses {
+			subClassTotal += subClassTarget.Percent
+
+			if len(subClassTarget.Funds) == 0 {
+				return fmt.Errorf("subclass %q of %q has no funds", subClassTarget.SubClass, classTarget.Class)
+			}
+			if err := validateFunds(string(subClassTarget.SubClass), classTarget.Class, subClassTarget.Funds); err != nil {
+				return err
+			}
+		}
+
+		if math.Abs(subClassTotal-1) > percentTolerance {
+			return fmt.Errorf("subclasses of %q add up to %f, should be 1", classTarget.Class, subClassTotal)
+		}
+	}
+
+	if math.Abs(classTotal-1) > percentTolerance {
+		return fmt.Errorf("classes add up to %f, should be 1", classTotal)
+	}
+
+	return nil
+}
+
+func validateFunds(group string, class asset.Class, funds []FundTarget) error {
+	for _, fund := range funds {
+		if fund.Weight < 0 {
+			return fmt.Errorf("%q has a negative weight for %q", group, fund.Symbol)
+		}
+
+		a, err := asset.GetAsset(fund.Symbol)
+		if err != nil {
+			continue
+		}
+
+		// Blended funds can be listed under any class they have a component in
+		weights, err := asset.ClassWeights(fund.Symbol)
+		if err != nil {
+			return err
+		}
+		if weights[class] > 0 {
+			continue
+		}
+		if len(a.Components) > 0 {
+			return fmt.Errorf("%q has no %s components but is listed under %s", fund.Symbol, class, class)
+		}
+		return fmt.Errorf("%q is a %s asset but is listed under %s", fund.Symbol, a.Class, class)
+	}
+
+	return nil
+}
+
+// LevelDrifts returns the drift at every level of the plan: each class, then
+// its subclasses, then their funds. Plans defined as a flat list of symbols are
+// grouped by the class and subclass the asset registry has for each symbol.
+// Blended funds count towards each class by the weight of their components,
+// like ClassDrifts, including classes they aren't listed under.
+func (plan *AllocationPlan) LevelDrifts() []Drift {
+	plan.computeCurrPercents()
+
+	bySymbol := map[string]*AssetAllocation{}
+	for _, aAllocation := range plan.Allocations {
+		bySymbol[aAllocation.Symbol] = aAllocation
+	}
+	classWeights := plan.classWeights()
+
+	drifts := []Drift{}
+	tree := plan.classTree()
+	inTree := map[asset.Class]bool{}
+	for _, classTarget := range tree {
+		inTree[classTarget.Class] = true
+	}
+	for _, class := range asset.GetAssetClasses() {
+		if !inTree[class] {
+			tree = append(tree, ClassTarget{Class: class})
+		}
+	}
+
+	for _, classTarget := range tree {
+		classIdx := len(drifts)
+		drifts = append(drifts, Drift{Name: string(classTarget.Class), Level: 0})
+
+		listed := map[string]bool{}
+		addFunds := func(funds []FundTarget, parents ...int) {
+			for _, fund := range funds {
+				listed[fund.Symbol] = true
+
+				aAllocation := bySymbol[fund.Symbol]
+				drifts = append(drifts, Drift{
+					Name:           fund.Symbol,
+					Level:          len(parents),
+					CurrPercent:    fund.Weight * aAllocation.CurrPercent,
+					DesiredPercent: fund.Weight * aAllocation.DesiredPercent,
+				})
+
+				for _, idx := range parents {
+					drifts[idx].CurrPercent += fund.Weight * aAllocation.CurrPercent
+					drifts[idx].DesiredPercent += fund.Weight * aAllocation.DesiredPercent
+				}
+			}
+		}
+
+		addFunds(classTarget.Funds, classIdx)
+		for _, subClassTarget := range classTarget.SubClasses {
+			subClassIdx := len(drifts)
+			drifts = append(drifts, Drift{Name: string(subClassTarget.SubClass), Level: 1})
+			addFunds(subClassTarget.Funds, classIdx, subClassIdx)
+		}
+
+		// The parts of blended funds in this class that are listed under another one
+		for _, aAllocation := range plan.Allocations {
+			if listed[aAllocation.Symbol] {
+				continue
+			}
+			weight := classWeights[aAllocation.Symbol][classTarget.Class]
+			drifts[classIdx].CurrPercent += weight * aAllocation.CurrPercent
+			drifts[classIdx].DesiredPercent += weight * aAllocation.DesiredPercent
+		}
+
+		if len(classTarget.Funds) == 0 && len(classTarget.SubClasses) == 0 &&
+			drifts[classIdx].CurrPercent == 0 && drifts[classIdx].DesiredPercent == 0 {
+			drifts = drifts[:classIdx]
+		}
+	}
+
+	return drifts
+}
+
+// classTree returns the plan's class hierarchy, built from the asset registry
+// for flat plans. Each fund's Weight is the fraction of it counted where it's
+// listed: the part of a blended fund in its class, or in its subclass for flat
+// plans, and all of any other fund.
+func (plan AllocationPlan) classTree() []ClassTarget {
+	if len(plan.Classes) > 0 {
+		classes := []ClassTarget{}
+		for _, classTarget := range plan.Classes {
+			classes = append(classes, ClassTarget{
+				Class:   classTarget.Class,
+				Percent: classTarget.Percent,
+				Funds:   classFunds(classTarget.Class, classTarget.Funds),
+			})
+			for _, subClassTarget := range classTarget.SubClasses {
+				subClassTarget.Funds = classFunds(classTarget.Class, subClassTarget.Funds)
+				classes[len(classes)-1].SubClasses = append(classes[len(classes)-1].SubClasses, subClassTarget)
+			}
+		}
+		return classes
+	}
+
+	classes := []ClassTarget{}
+	classIdx := map[asset.Class]int{}
+	addFund := func(class asset.Class, subClass asset.SubClass, fund FundTarget) {
+		idx, ok := classIdx[class]
+		if !ok {
+			idx = len(classes)
+			classIdx[class] = idx
+			classes = append(classes, ClassTarget{Class: class})
+		}
+		classTarget := &classes[idx]
+
+		// Components given as a class alone are listed directly under it
+		if subClass == "" {
+			classTarget.Funds = append(classTarget.Funds, fund)
+			return
+		}
+
+		for subIdx := range classTarget.SubClasses {
+			if classTarget.SubClasses[subIdx].SubClass == subClass {
+				classTarget.SubClasses[subIdx].Funds = append(classTarget.SubClasses[subIdx].Funds, fund)
+				return
+			}
+		}
+		classTarget.SubClasses = append(classTarget.SubClasses, SubClassTarget{
+			SubClass: subClass,
+			Funds:    []FundTarget{fund},
+		})
+	}
+
+	for _, aAllocation := range plan.Allocations {
+		weights, err := asset.SubClassWeights(aAllocation.Symbol)
+		if err != nil {
+			addFund(unclassified, unclassified, FundTarget{Symbol: aAllocation.Symbol, Weight: 1})
+			continue
+		}
+
+		for _, class := range asset.GetAssetClasses() {
+			subClasses := []asset.SubClass{}
+			for subClass := range weights[class] {
+				subClasses = append(subClasses, subClass)
+			}
+			sort.Slice(subClasses, func(i, j int) bool {
+				return subClasses[i] < subClasses[j]
+			})
+
+			for _, subClass := range subClasses {
+				addFund(class, subClass, FundTarget{Symbol: aAllocation.Symbol, Weight: weights[class][subClass]})
+			}
+		}
+	}
+
+	return classes
+}
+
+// classFunds returns the funds of a hierarchical plan's class or subclass
+// weighted by the part of each fund in class
+func classFunds(class asset.Class, funds []FundTarget) []FundTarget {
+	weighted := []FundTarget{}
+	for _, fund := range funds {
+		fund.Weight = 1
+		if weights, err := asset.ClassWeights(fund.Symbol); err == nil {
+			fund.Weight = weights[class]
+		}
+		weighted = append(weighted, fund)
+	}
+
+	return weighted
+}
@@ -0,0 +1,184 @@
+package allocations
+
+import (
+	"sort"
+
+	"github.com/samkreter/portfoli/asset"
+	"github.com/samkreter/portfoli/pkg/money"
+	"github.com/samkreter/portfoli/portfolio"
+)
+
+// LocationPreference orders the account tax types an asset class, or a
+// subclass when set, should be held in, most preferred first. When accounts
+// run out of room, assets whose preference is listed first are placed first.
+type LocationPreference struct {
+	Class    asset.Class         `json:"class,omitempty"`
+	SubClass asset.SubClass      `json:"subClass,omitempty"`
+	TaxTypes []portfolio.TaxType `json:"taxTypes"`
+}
+
+// defaultLocationPreferences are used for plans without location preferences.
+// Bonds and REITs are tax inefficient so they go in tax-deferred accounts,
+// international equity goes in taxable accounts for the foreign tax credit.
+var defaultLocationPreferences = []LocationPreference{
+	{Class: asset.Bond, TaxTypes: []portfolio.TaxType{portfolio.Traditional, portfolio.HSA, portfolio.Roth, portfolio.Taxable}},
+	{Class: asset.RealEstate, TaxTypes: []portfolio.TaxType{portfolio.Traditional, portfolio.HSA, portfolio.Roth, portfolio.Taxable}},
+	{SubClass: asset.International, TaxTypes: []portfolio.TaxType{portfolio.Taxable}},
+	{SubClass: asset.EmergingMarkets, TaxTypes: []portfolio.TaxType{portfolio.Taxable}},
+}
+
+// locationPreference returns the preference for the asset, subclass preferences
+// win over class preferences. rank orders the assets to place, lowest first:
+// subclass preferences, then class preferences, each in the order listed, and
+// assets without a preference last. ok is false if the asset has none.
+func (plan AllocationPlan) locationPreference(a AssetAllocation) (pref LocationPreference, rank int, ok bool) {
+	prefs := plan.Locations
+	if len(prefs) == 0 {
+		prefs = defaultLocationPreferences
+	}
+
+	registered, err := asset.GetAsset(a.Symbol)
+	if err != nil {
+		return LocationPreference{}, 2 * len(prefs), false
+	}
+
+	for idx, p := range prefs {
+		if p.SubClass != "" && p.SubClass == registered.SubClass {
+			return p, idx, true
+		}
+	}
+	for idx, p := range prefs {
+		if p.SubClass == "" && p.Class == registered.Class {
+			return p, len(prefs) + idx, true
+		}
+	}
+
+	return LocationPreference{}, 2 * len(prefs), false
+}
+
+// Locate updates the desired values of the plan for a full rebalance at
+// constant total value and returns the per account trades that reach it while
+// holding each asset in the account tax types it prefers. Every account keeps
+// its current value, no money moves between accounts, the cash an account
+// holds outside of the plan's assets is invested there. accountValues is the
+// value of each symbol held, keyed by account, accountCash the cash held
+// outside of the plan's assets in each account and taxTypes the tax type of
+// each account, accounts without one are treated as taxable. unplaced is the
+// value of each asset that didn't fit in any account.
+func (plan *AllocationPlan) Locate(accountValues map[string]map[string]money.Money, accountCash map[string]money.Money, taxTypes map[string]portfolio.TaxType) (trades []AccountTrade, unplaced map[string]money.Money, err error) {
+	if err := plan.Rebalance(FullRebalance, 0); err != nil {
+		return nil, nil, err
+	}
+
+	accounts := []string{}
+	for account := range accountValues {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+
+	// Only the plan's holdings and cash are moved, anything else in an account is left alone
+	held := map[*AssetAllocation]map[string]money.Money{}
+	capacity := map[string]money.Money{}
+	for _, account := range accounts {
+		capacity[account] = accountCash[account]
+	}
+	for _, aAllocation := range plan.Allocations {
+		held[aAllocation] = map[string]money.Money{}
+		for _, account := range accounts {
+			for _, symbol := range aAllocation.Symbols() {
+				held[aAllocation][account] += accountValues[account][symbol]
+			}
+			capacity[account] += held[aAllocation][account]
+		}
+	}
+
+	// Place the assets with the strongest preference first, larger assets first within a rank
+	ordered := append([]*AssetAllocation{}, plan.Allocations...)
+	rank := func(a *AssetAllocation) int {
+		_, r, _ := plan.locationPreference(*a)
+		return r
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if rank(ordered[i]) != rank(ordered[j]) {
+			return rank(ordered[i]) < rank(ordered[j])
+		}
+		return ordered[i].DesiredValue > ordered[j].DesiredValue
+	})
+
+	trades = []AccountTrade{}
+	unplaced = map[string]money.Money{}
+	for _, aAllocation := range ordered {
+		pref, _, _ := plan.locationPreference(*aAllocation)
+		remaining := aAllocation.DesiredValue
+		targets := map[string]money.Money{}
+
+		for _, account := range locationOrder(accounts, pref.TaxTypes, taxTypes, held[aAllocation]) {
+			if remaining <= 0 {
+				break
+			}
+
+			amount := remaining
+			if capacity[account] < amount {
+				amount = capacity[account]
+			}
+			targets[account] = amount
+			capacity[account] -= amount
+			remaining -= amount
+		}
+		if remaining.Round() > 0 {
+			unplaced[aAllocation.Symbol] = remaining.Round()
+		}
+
+		for _, account := range accounts {
+			amount := (targets[account] - held[aAllocation][account]).Round()
+			if amount == 0 {
+				continue
+			}
+
+			symbol := aAllocation.PreferredSymbol(account, accountValues[account])
+			if amount < 0 {
+				symbol = aAllocation.largestHolding(accountValues[account])
+			}
+
+			trades = append(trades, AccountTrade{
+				Account: account,
+				Symbol:  symbol,
+				Amount:  amount,
+			})
+		}
+	}
+
+	sort.SliceStable(trades, func(i, j int) bool {
+		return trades[i].Account < trades[j].Account
+	})
+
+	return trades, unplaced, nil
+}
+
+// locationOrder orders the accounts to fill with an asset: by the preferred
+// tax types first, then accounts already holding the most of the asset
+func locationOrder(accounts []string, preferred []portfolio.TaxType, taxTypes map[string]portfolio.TaxType, held map[string]money.Money) []string {
+	taxTypeRank := func(account string) int {
+		taxType, ok := taxTypes[account]
+		if !ok {
+			taxType = portfolio.Taxable
+		}
+
+		for idx, t := range preferred {
+			if t == taxType {
+				return idx
+			}
+		}
+		return len(preferred)
+	}
+
+	ordered := append([]string{}, accounts...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if taxTypeRank(ordered[i]) != taxTypeRank(ordered[j]) {
+			return taxTypeRank(ordered[i]) < taxTypeRank(ordered[j])
+		}
+		return held[ordered[i]] > held[ordered[j]]
+	})
+
+	return ordered
+}
@@ -0,0 +1,218 @@
+package allocations
+
+import (
+	"math"
+	"sort"
+
+	"github.com/samkreter/portfoli/pkg/money"
+)
+
+// Order is a buy (positive Shares) or sell (negative Shares) of an asset at its last price
+type Order struct {
+	Symbol string
+	Shares float64
+	Price  money.Money
+}
+
+// Amount returns the value of the order rounded to the cent, negative for sells
+func (o Order) Amount() money.Money {
+	return o.Price.Mul(o.Shares).Round()
+}
+
+// Action returns "Buy" or "Sell"
+func (o Order) Action() string {
+	if o.Shares > 0 {
+		return "Buy"
+	}
+	return "Sell"
+}
+
+// OrderList is the plan's trades turned into share quantities
+type OrderList struct {
+	Orders []Order
+
+	// Unpriced are the trades, or the part of a sell, that could not be turned
+	// into shares since the asset has no price or no priced fund to sell
+	Unpriced []Trade
+
+	// LeftoverCash is the money the trades called for that rounding left uninvested
+	LeftoverCash money.Money
+}
+
+// Orders turns the plan's trades into share orders using the last price of
+// each fund. Buys are placed in the asset's preferred fund and sells come out
+// of the funds held, largest first, like AccountTrades does for each account.
+// Shares are rounded to precision decimal places, 0 for whole shares. Buys are
+// rounded down and sells up, without going over the quantity held, so the
+// orders never need more cash than the trades. The cash left over from
+// rounding buys more shares of the assets furthest below their desired value.
+// A negative precision is treated as whole shares.
+func (plan AllocationPlan) Orders(prices map[string]money.Money, quantities map[string]float64, precision int) OrderList {
+	orderList := OrderList{}
+
+	if precision < 0 {
+		precision = 0
+	}
+
+	step := math.Pow(10, -float64(precision))
+	roundDown := func(shares float64) float64 {
+		return math.Floor(shares/step+1e-9) * step
+	}
+	roundUp := func(shares float64) float64 {
+		return math.Ceil(shares/step-1e-9) * step
+	}
+
+	// held is the value of each fund, to pick the funds an asset is traded in
+	held := map[string]money.Money{}
+	for symbol, quantity := range quantities {
+		held[symbol] = prices[symbol].Mul(quantity)
+	}
+
+	orders := map[string]*Order{}
+	budget := money.Money(0)
+	for _, trade := range plan.Trades() {
+		aAllocation := plan.allocation(trade.Symbol)
+
+		if trade.IsBuy() {
+			symbol := aAllocation.PreferredSymbol("", held)
+			price := prices[symbol]
+			if price <= 0 {
+				orderList.Unpriced = append(orderList.Unpriced, trade)
+				continue
+			}
+			budget += trade.Amount
+
+			orders[symbol] = &Order{Symbol: symbol, Shares: roundDown(trade.Amount.Ratio(price)), Price: price}
+			continue
+		}
+
+		remaining := -trade.Amount
+		for _, symbol := range aAllocation.holdingsBySize(held) {
+			if remaining <= 0 {
+				break
+			}
+
+			sell := money.Min(remaining, held[symbol])
+			remaining -= sell
+			budget -= sell
+
+			shares := math.Min(roundUp(sell.Ratio(prices[symbol])), quantities[symbol])
+			orders[symbol] = &Order{Symbol: symbol, Shares: -shares, Price: prices[symbol]}
+		}
+		if remaining > 0 {
+			orderList.Unpriced = append(orderList.Unpriced, Trade{Symbol: trade.Symbol, Amount: -remaining})
+		}
+	}
+
+	leftover := budget
+	for _, order := range orders {
+		leftover -= order.Amount()
+	}
+
+	// Spend what rounding left over on the asset furthest below its desired
+	// value, as many steps as fill it up to its desired value, then the next
+	full := map[*AssetAllocation]bool{}
+	for {
+		var best *AssetAllocation
+		bestShortfall := money.Money(0)
+		for _, aAllocation := range plan.Allocations {
+			price := prices[aAllocation.PreferredSymbol("", held)]
+			if full[aAllocation] || price <= 0 || price.Float64()*step > leftover.Float64() {
+				continue
+			}
+
+			shortfall := aAllocation.DesiredValue - aAllocation.CurrValue
+			selling := false
+			for _, symbol := range aAllocation.Symbols() {
+				if order, ok := orders[symbol]; ok {
+					shortfall -= order.Amount()
+					selling = selling || order.Shares < 0
+				}
+			}
+
+			// Never turn a sell into less of a sell than planned
+			if selling {
+				continue
+			}
+
+			if shortfall > bestShortfall {
+				best = aAllocation
+				bestShortfall = shortfall
+			}
+		}
+		if best == nil {
+			break
+		}
+
+		symbol := best.PreferredSymbol("", held)
+		order, ok := orders[symbol]
+		if !ok {
+			order = &Order{Symbol: symbol, Price: prices[symbol]}
+			orders[symbol] = order
+		}
+
+		stepCost := order.Price.Float64() * step
+		steps := math.Max(1, math.Floor(money.Min(leftover, bestShortfall).Float64()/stepCost))
+
+		// Amounts are rounded to the cent, back off until the order fits what's left
+		shares, before := order.Shares, order.Amount()
+		for ; steps > 0; steps-- {
+			order.Shares = roundDown(shares + steps*step + step/2)
+			if order.Amount()-before <= leftover {
+				break
+			}
+		}
+		if steps == 0 || order.Amount() == before {
+			order.Shares = shares
+			full[best] = true
+			continue
+		}
+		leftover -= order.Amount() - before
+	}
+	orderList.LeftoverCash = leftover
+
+	for _, aAllocation := range plan.Allocations {
+		for _, symbol := range aAllocation.Symbols() {
+			if order, ok := orders[symbol]; ok && order.Shares != 0 {
+				orderList.Orders = append(orderList.Orders, *order)
+			}
+		}
+	}
+	sort.SliceStable(orderList.Orders, func(i, j int) bool {
+		// Sells first so their cash is available for the buys
+		return orderList.Orders[i].Shares < 0 && orderList.Orders[j].Shares > 0
+	})
+
+	return orderList
+}
+
+// ValuesAfter returns the value of each asset after the orders are filled,
+// including the trades left unpriced, which are made by amount
+func (plan AllocationPlan) ValuesAfter(orderList OrderList) map[string]money.Money {
+	values := map[string]money.Money{}
+	for _, aAllocation := range plan.Allocations {
+		values[aAllocation.Symbol] = aAllocation.CurrValue
+	}
+	for _, order := range orderList.Orders {
+		if aAllocation := plan.allocation(order.Symbol); aAllocation != nil {
+			values[aAllocation.Symbol] += order.Amount()
+		}
+	}
+	for _, trade := range orderList.Unpriced {
+		if aAllocation := plan.allocation(trade.Symbol); aAllocation != nil {
+			values[aAllocation.Symbol] += trade.Amount
+		}
+	}
+
+	return values
+}
+
+// allocation returns the plan's asset holding symbol, nil if none does
+func (plan AllocationPlan) allocation(symbol string) *AssetAllocation {
+	for _, aAllocation := range plan.Allocations {
+		if aAllocation.HasSymbol(symbol) {
+			return aAllocation
+		}
+	}
+	return nil
+}
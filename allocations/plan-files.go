@@ -0,0 +1,157 @@
+package allocations
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/samkreter/portfoli/pkg/config"
+	"gopkg.in/yaml.v2"
+)
+
+// planFileExts are the extensions of plan files, YAML plans use the same keys as JSON ones
+var planFileExts = []string{".json", ".yaml", ".yml"}
+
+// LoadPlanFile reads and validates an allocation plan from a JSON or YAML file
+func LoadPlanFile(path string) (AllocationPlan, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return AllocationPlan{}, err
+	}
+
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+		if data, err = yamlToJSON(data); err != nil {
+			return AllocationPlan{}, fmt.Errorf("failed to parse plan file %q: %v", path, err)
+		}
+	}
+
+	var plan AllocationPlan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return AllocationPlan{}, fmt.Errorf("failed to parse plan file %q: %v", path, err)
+	}
+
+	// Default the name to the file name so a plan can be referenced without one
+	if plan.Name == "" {
+		plan.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	// Hierarchical plans are flattened into symbol targets
+	if len(plan.Classes) > 0 {
+		if len(plan.Allocations) > 0 {
+			return AllocationPlan{}, fmt.Errorf("invalid plan file %q: plan has both classes and allocations", path)
+		}
+
+		if err := plan.Flatten(); err != nil {
+			return AllocationPlan{}, fmt.Errorf("invalid plan file %q: %v", path, err)
+		}
+	}
+
+	if err := plan.Validate(); err != nil {
+		return AllocationPlan{}, fmt.Errorf("invalid plan file %q: %v", path, err)
+	}
+
+	return plan, nil
+}
+
+// LoadPlansDir loads every plan file in dir keyed by plan name. A missing
+// directory is not an error.
+func LoadPlansDir(dir string) (map[string]AllocationPlan, error) {
+	plans := map[string]AllocationPlan{}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return plans, nil
+		}
+		return nil, err
+	}
+
+	for _, file := range files {
+		if file.IsDir() || !isPlanFile(file.Name()) {
+			continue
+		}
+
+		plan, err := LoadPlanFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := plans[plan.Name]; ok {
+			return nil, fmt.Errorf("allocation plan %q is defined more than once in %q", plan.Name, dir)
+		}
+		plans[plan.Name] = plan
+	}
+
+	return plans, nil
+}
+
+func loadUserPlans() (map[string]AllocationPlan, error) {
+	dir, err := config.PlansDir()
+	if err != nil {
+		// Without a config home only the built-in plans are available
+		return map[string]AllocationPlan{}, nil
+	}
+
+	return LoadPlansDir(dir)
+}
+
+func isPlanFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, planFileExt := range planFileExts {
+		if ext == planFileExt {
+			return true
+		}
+	}
+	return false
+}
+
+// yamlToJSON converts a YAML document to JSON so it decodes with the plan's JSON keys
+func yamlToJSON(data []byte) ([]byte, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	doc, err := jsonValue(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(doc)
+}
+
+// jsonValue replaces the map[interface{}]interface{} YAML decodes mappings to
+// with maps JSON can encode
+func jsonValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := map[string]interface{}{}
+		for key, value := range v {
+			keyStr, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("key %v is not a string", key)
+			}
+
+			converted, err := jsonValue(value)
+			if err != nil {
+				return nil, err
+			}
+			m[keyStr] = converted
+		}
+		return m, nil
+	case []interface{}:
+		for idx, value := range v {
+			converted, err := jsonValue(value)
+			if err != nil {
+				return nil, err
+			}
+			v[idx] = converted
+		}
+		return v, nil
+	default:
+		return v, nil
+	}
+}
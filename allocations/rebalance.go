@@ -0,0 +1,154 @@
+package allocations
+
+import (
+	"fmt"
+
+	"github.com/samkreter/portfoli/pkg/money"
+)
+
+// Strategy is how a plan moves from its current values to its desired values
+type Strategy string
+
+const (
+	// BuyOnly never sells, the plan is scaled up until the most overweight asset is on target
+	BuyOnly = Strategy("buy-only")
+	// FullRebalance sells overweight and buys underweight assets keeping the total value,
+	// plus the available cash, constant
+	FullRebalance = Strategy("full")
+	// Hybrid deploys the available cash first and only sells what the cash can't cover
+	Hybrid = Strategy("hybrid")
+)
+
+// Strategies returns all rebalance strategies
+func Strategies() []Strategy {
+	return []Strategy{BuyOnly, FullRebalance, Hybrid}
+}
+
+// Trade is a buy (positive Amount) or sell (negative Amount) of an asset
+type Trade struct {
+	Symbol string
+	Amount money.Money
+}
+
+// IsBuy reports whether the trade is a purchase
+func (t Trade) IsBuy() bool {
+	return t.Amount > 0
+}
+
+// Action returns "Buy" or "Sell"
+func (t Trade) Action() string {
+	if t.IsBuy() {
+		return "Buy"
+	}
+	return "Sell"
+}
+
+// Rebalance updates the desired values of the plan using strategy. cash is the
+// money available to invest on top of the current value, only Hybrid uses it.
+// The held cash above the plan's cash target is invested first, see AvailableCash.
+func (plan *AllocationPlan) Rebalance(strategy Strategy, cash money.Money) error {
+	switch strategy {
+	case BuyOnly:
+		if err := plan.UpdateDesiredValues(); err != nil {
+			return err
+		}
+
+		// Cash on hand past what puts every asset on target is invested too
+		if availableTotal := plan.GetCurrTotalVal() + plan.AvailableCash(); availableTotal > plan.buyOnlyTotal() {
+			plan.computeDesiredValues(availableTotal)
+			for _, aAllocation := range plan.Allocations {
+				aAllocation.DesiredValue = money.Max(aAllocation.DesiredValue, aAllocation.CurrValue)
+			}
+		}
+	case FullRebalance:
+		plan.computeCurrPercents()
+		plan.computeDesiredValues(plan.GetCurrTotalVal() + plan.AvailableCash())
+	case Hybrid:
+		plan.computeCurrPercents()
+
+		// All the cash is invested, when it covers a buy-only rebalance nothing
+		// has to be sold
+		availableTotal := plan.GetCurrTotalVal() + plan.AvailableCash() + cash
+		plan.computeDesiredValues(availableTotal)
+		if plan.buyOnlyTotal() <= availableTotal {
+			for _, aAllocation := range plan.Allocations {
+				aAllocation.DesiredValue = money.Max(aAllocation.DesiredValue, aAllocation.CurrValue)
+			}
+		}
+	default:
+		return fmt.Errorf("invalid rebalance strategy: %s", strategy)
+	}
+
+	return plan.Validate()
+}
+
+// Trades returns the trades that move each asset from its current to its
+// desired value, rounded to the cent
+func (plan AllocationPlan) Trades() []Trade {
+	trades := []Trade{}
+	for _, aAllocation := range plan.Allocations {
+		amount := (aAllocation.DesiredValue - aAllocation.CurrValue).Round()
+		if amount == 0 {
+			continue
+		}
+
+		trades = append(trades, Trade{
+			Symbol: aAllocation.Symbol,
+			Amount: amount,
+		})
+	}
+
+	return trades
+}
+
+// Hold keeps the asset at its current value, dropping its trade from the plan.
+// When that drops a sell, the buys it was paying for are scaled down by what it
+// would have raised so the plan needs no more cash than before. Hold returns
+// how much the buys were cut by.
+func (plan *AllocationPlan) Hold(symbol string) money.Money {
+	unfunded := money.Money(0)
+	for _, aAllocation := range plan.Allocations {
+		if aAllocation.Symbol == symbol {
+			unfunded += money.Max(aAllocation.CurrValue-aAllocation.DesiredValue, 0)
+			aAllocation.DesiredValue = aAllocation.CurrValue
+		}
+	}
+
+	buys := []*AssetAllocation{}
+	weights := []float64{}
+	total := money.Money(0)
+	for _, aAllocation := range plan.Allocations {
+		if amount := aAllocation.DesiredValue - aAllocation.CurrValue; amount > 0 {
+			buys = append(buys, aAllocation)
+			weights = append(weights, amount.Float64())
+			total += amount
+		}
+	}
+	if unfunded == 0 || total == 0 {
+		return 0
+	}
+
+	cut := money.Min(unfunded, total)
+	for idx, amount := range (total - cut).Allocate(weights) {
+		buys[idx].DesiredValue = buys[idx].CurrValue + amount
+	}
+
+	return cut
+}
+
+// buyOnlyTotal returns the smallest total value that puts every asset on
+// target without selling, the current total if no asset is held
+func (plan AllocationPlan) buyOnlyTotal() money.Money {
+	total := plan.GetCurrTotalVal()
+	for _, aAllocation := range plan.Allocations {
+		if aAllocation.DesiredPercent == 0 {
+			continue
+		}
+
+		if assetTotal := aAllocation.CurrValue.Div(aAllocation.DesiredPercent); assetTotal > total {
+			total = assetTotal
+		}
+	}
+
+	return total
+}
@@ -0,0 +1,159 @@
+package allocations
+
+import (
+	"sort"
+
+	"github.com/samkreter/portfoli/asset"
+	"github.com/samkreter/portfoli/pkg/money"
+)
+
+// AccountTrade is a buy (positive Amount) or sell (negative Amount) of a fund in a single account
+type AccountTrade struct {
+	Account string
+	Symbol  string
+	Amount  money.Money
+}
+
+// Symbols returns the preferred symbol of the asset followed by its equivalents
+func (a AssetAllocation) Symbols() []string {
+	return append([]string{a.Symbol}, a.Equivalents...)
+}
+
+// AddGroupEquivalents adds each symbol the plan doesn't list as an equivalent
+// of the plan's asset in the same asset group, so held funds from the group
+// count towards it
+func (plan *AllocationPlan) AddGroupEquivalents(symbols []string) {
+	for _, symbol := range symbols {
+		if plan.HasSymbol(symbol) {
+			continue
+		}
+
+		for _, aAllocation := range plan.Allocations {
+			if asset.SameGroup(aAllocation.Symbol, symbol) {
+				aAllocation.Equivalents = append(aAllocation.Equivalents, symbol)
+				break
+			}
+		}
+	}
+}
+
+// HasSymbol reports whether any of the plan's assets holds symbol
+func (plan AllocationPlan) HasSymbol(symbol string) bool {
+	for _, aAllocation := range plan.Allocations {
+		if aAllocation.HasSymbol(symbol) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasSymbol reports whether symbol is the asset's symbol or one of its equivalents
+func (a AssetAllocation) HasSymbol(symbol string) bool {
+	for _, s := range a.Symbols() {
+		if s == symbol {
+			return true
+		}
+	}
+	return false
+}
+
+// PreferredSymbol returns the fund to buy the asset with in account: the
+// account's override, else Symbol if the account holds it or holds none of the
+// equivalents, else the equivalent the account holds the most of.
+func (a AssetAllocation) PreferredSymbol(account string, accountValues map[string]money.Money) string {
+	if symbol, ok := a.AccountSymbols[account]; ok {
+		return symbol
+	}
+
+	if accountValues[a.Symbol] > 0 {
+		return a.Symbol
+	}
+
+	return a.largestHolding(accountValues)
+}
+
+// largestHolding returns the asset's fund the account holds the most of, Symbol if it holds none
+func (a AssetAllocation) largestHolding(accountValues map[string]money.Money) string {
+	largest := a.Symbol
+	for _, symbol := range a.Symbols() {
+		if accountValues[symbol] > accountValues[largest] {
+			largest = symbol
+		}
+	}
+
+	return largest
+}
+
+// holdingsBySize returns the asset's funds with a value in held, largest first
+func (a AssetAllocation) holdingsBySize(held map[string]money.Money) []string {
+	symbols := []string{}
+	for _, symbol := range a.Symbols() {
+		if held[symbol] > 0 {
+			symbols = append(symbols, symbol)
+		}
+	}
+
+	sort.SliceStable(symbols, func(i, j int) bool {
+		return held[symbols[i]] > held[symbols[j]]
+	})
+
+	return symbols
+}
+
+// AccountTrades splits the plan's trades between the accounts holding each
+// asset, in proportion to the asset's value in each account. Buys are placed
+// in each account's preferred fund and sells in the fund it holds the most of.
+// accountValues is the value of each symbol held, keyed by account. Trades of
+// assets no account holds are returned without an account in the asset's symbol.
+func (plan AllocationPlan) AccountTrades(accountValues map[string]map[string]money.Money) []AccountTrade {
+	accounts := []string{}
+	for account := range accountValues {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+
+	trades := []AccountTrade{}
+	for _, aAllocation := range plan.Allocations {
+		amount := (aAllocation.DesiredValue - aAllocation.CurrValue).Round()
+		if amount == 0 {
+			continue
+		}
+
+		held := map[string]money.Money{}
+		weights := make([]float64, len(accounts))
+		total := money.Money(0)
+		for idx, account := range accounts {
+			for _, symbol := range aAllocation.Symbols() {
+				held[account] += accountValues[account][symbol]
+			}
+			weights[idx] = held[account].Float64()
+			total += held[account]
+		}
+
+		if total == 0 {
+			trades = append(trades, AccountTrade{Symbol: aAllocation.Symbol, Amount: amount})
+			continue
+		}
+
+		// Whole cents per account that add up to the asset's trade
+		amounts := amount.Allocate(weights)
+		for idx, account := range accounts {
+			if held[account] == 0 || amounts[idx] == 0 {
+				continue
+			}
+
+			symbol := aAllocation.PreferredSymbol(account, accountValues[account])
+			if amount < 0 {
+				symbol = aAllocation.largestHolding(accountValues[account])
+			}
+
+			trades = append(trades, AccountTrade{
+				Account: account,
+				Symbol:  symbol,
+				Amount:  amounts[idx],
+			})
+		}
+	}
+
+	return trades
+}
@@ -0,0 +1,87 @@
+package allocations
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/samkreter/portfoli/pkg/money"
+)
+
+// Withdraw updates the desired values of the plan to raise amount by selling.
+// Assets the plan doesn't want are sold first, then the most overweight assets
+// are trimmed down towards their targets so the plan ends up as close to its
+// targets as selling alone allows. An asset is never sold below 0.
+func (plan *AllocationPlan) Withdraw(amount money.Money) error {
+	if amount < 0 {
+		return errors.New("withdrawal must not be negative")
+	}
+
+	currTotal := plan.GetCurrTotalVal()
+	if amount > currTotal {
+		return fmt.Errorf("withdrawal of %s is more than the plan's current value of %s", amount, currTotal)
+	}
+
+	plan.computeCurrPercents()
+
+	// Sell the assets without a target first
+	remaining := amount
+	for _, aAllocation := range plan.Allocations {
+		aAllocation.DesiredValue = aAllocation.CurrValue
+		if aAllocation.DesiredPercent == 0 {
+			sell := money.Min(remaining, aAllocation.CurrValue)
+			aAllocation.DesiredValue -= sell
+			remaining -= sell
+		}
+	}
+	if remaining == 0 {
+		return plan.Validate()
+	}
+
+	// Lowering the drain level to a total of level sells every asset whose
+	// current value is above DesiredPercent*level down to that value
+	level, ok := plan.drainLevel(currTotal - amount)
+	if !ok {
+		return plan.Validate()
+	}
+	plan.computeDesiredValues(level)
+	for _, aAllocation := range plan.Allocations {
+		aAllocation.DesiredValue = money.Min(aAllocation.DesiredValue, aAllocation.CurrValue)
+	}
+	plan.settle(currTotal - amount)
+
+	return plan.Validate()
+}
+
+// drainLevel returns the plan total at which selling every asset above its
+// target down to that total leaves the targeted assets worth remainingTotal,
+// ok is false when no asset has to be sold
+func (plan AllocationPlan) drainLevel(remainingTotal money.Money) (level money.Money, ok bool) {
+	funded := []*AssetAllocation{}
+	percentSum := 0.0
+	for _, aAllocation := range plan.Allocations {
+		if aAllocation.DesiredPercent > 0 {
+			funded = append(funded, aAllocation)
+			percentSum += aAllocation.DesiredPercent
+		}
+	}
+
+	// Least overweight first, these keep their current value the longest
+	sort.Slice(funded, func(i, j int) bool {
+		return funded[i].CurrValue.Div(funded[i].DesiredPercent) < funded[j].CurrValue.Div(funded[j].DesiredPercent)
+	})
+
+	keptSum := money.Money(0)
+	for _, aAllocation := range funded {
+		level := (remainingTotal - keptSum).Div(percentSum)
+		if level <= aAllocation.CurrValue.Div(aAllocation.DesiredPercent) {
+			return level, true
+		}
+
+		// The asset is below the level so it isn't sold
+		keptSum += aAllocation.CurrValue
+		percentSum -= aAllocation.DesiredPercent
+	}
+
+	return 0, false
+}
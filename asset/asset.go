@@ -1,5 +1,7 @@
 package asset
 
+import "fmt"
+
 // GetAsset gets an asset by symbol
 func GetAsset(symbol string) (Asset, error) {
 	asset, ok := knownAssets[symbol]
@@ -18,3 +20,92 @@ func GetAssetClasses() []Class {
 		RealEstate,
 	}
 }
+
+// GetSubstitutes gets the known substitutes of an asset, in order of preference
+func GetSubstitutes(symbol string) ([]Asset, error) {
+	a, err := GetAsset(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	substitutes := []Asset{}
+	for _, substituteSymbol := range a.Substitutes {
+		substitute, err := GetAsset(substituteSymbol)
+		if err != nil {
+			return nil, fmt.Errorf("substitute %q of %q: %v", substituteSymbol, symbol, err)
+		}
+		substitutes = append(substitutes, substitute)
+	}
+
+	return substitutes, nil
+}
+
+// maxComponentDepth bounds how deep blended funds can be nested, it catches
+// funds that are components of each other
+const maxComponentDepth = 8
+
+// ClassWeights returns the fraction of the asset in each class, looking
+// through blended funds to their components. A plain asset is all its class.
+func ClassWeights(symbol string) (map[Class]float64, error) {
+	subClassWeights, err := SubClassWeights(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	weights := map[Class]float64{}
+	for class, subClasses := range subClassWeights {
+		for _, weight := range subClasses {
+			weights[class] += weight
+		}
+	}
+
+	return weights, nil
+}
+
+// SubClassWeights returns the fraction of the asset in each class and subclass,
+// looking through blended funds to their components. Components given as a
+// class alone are in an empty subclass.
+func SubClassWeights(symbol string) (map[Class]map[SubClass]float64, error) {
+	weights := map[Class]map[SubClass]float64{}
+	if err := addSubClassWeights(weights, symbol, 1, 0); err != nil {
+		return nil, err
+	}
+
+	return weights, nil
+}
+
+func addSubClassWeights(weights map[Class]map[SubClass]float64, symbol string, weight float64, depth int) error {
+	if depth > maxComponentDepth {
+		return fmt.Errorf("components of %q are nested too deep or circular", symbol)
+	}
+
+	a, err := GetAsset(symbol)
+	if err != nil {
+		return fmt.Errorf("asset %q: %v", symbol, err)
+	}
+
+	add := func(class Class, subClass SubClass, weight float64) {
+		if weights[class] == nil {
+			weights[class] = map[SubClass]float64{}
+		}
+		weights[class][subClass] += weight
+	}
+
+	if len(a.Components) == 0 {
+		add(a.Class, a.SubClass, weight)
+		return nil
+	}
+
+	for _, component := range a.Components {
+		if component.Symbol == "" {
+			add(component.Class, component.SubClass, weight*component.Weight)
+			continue
+		}
+
+		if err := addSubClassWeights(weights, component.Symbol, weight*component.Weight, depth+1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
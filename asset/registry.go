@@ -0,0 +1,219 @@
+package asset
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"sort"
+)
+
+// weightTolerance is how far the component weights of a blended fund can be from adding up to 1
+const weightTolerance = 0.0001
+
+// AssetsFile is the JSON file of user defined assets
+type AssetsFile struct {
+	Assets []Asset `json:"assets"`
+}
+
+// LoadFile merges the assets in the JSON file at path over the known assets.
+// The fields set for a known symbol replace its built-in ones. Nothing is
+// merged if any asset in the file is invalid.
+func LoadFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var assetsFile AssetsFile
+	if err := json.Unmarshal(data, &assetsFile); err != nil {
+		return fmt.Errorf("failed to parse assets file %q: %v", path, err)
+	}
+
+	previous := map[string]Asset{}
+	for symbol, a := range knownAssets {
+		previous[symbol] = a
+	}
+
+	if err := addAll(assetsFile.Assets); err != nil {
+		knownAssets = previous
+		return fmt.Errorf("assets file %q: %v", path, err)
+	}
+
+	return nil
+}
+
+// addAll adds the assets, blended funds are checked once every asset they can
+// be made of is added
+func addAll(assets []Asset) error {
+	for _, a := range assets {
+		if err := add(a); err != nil {
+			return err
+		}
+	}
+
+	for _, a := range assets {
+		if err := resolveClass(a.Symbol); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Register adds an asset, merging it over the known asset of the same symbol
+func Register(a Asset) error {
+	previous, known := knownAssets[a.Symbol]
+	if err := addAll([]Asset{a}); err != nil {
+		if known {
+			knownAssets[a.Symbol] = previous
+		} else {
+			delete(knownAssets, a.Symbol)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// add merges the asset over the known one without checking its class
+func add(a Asset) error {
+	if a.Symbol == "" {
+		return fmt.Errorf("asset %q has no symbol", a.Name)
+	}
+
+	if known, ok := knownAssets[a.Symbol]; ok {
+		a = merge(known, a)
+	}
+
+	if a.ExpenseRatio < 0 {
+		return fmt.Errorf("asset %q has a negative expense ratio", a.Symbol)
+	}
+	if err := validateComponents(a); err != nil {
+		return err
+	}
+
+	knownAssets[a.Symbol] = a
+	return nil
+}
+
+// resolveClass checks the class of the asset, blended funds without one get
+// the largest class of their components
+func resolveClass(symbol string) error {
+	a := knownAssets[symbol]
+	if len(a.Components) > 0 {
+		weights, err := ClassWeights(symbol)
+		if err != nil {
+			return err
+		}
+
+		if a.Class == "" {
+			for _, class := range GetAssetClasses() {
+				if a.Class == "" || weights[class] > weights[a.Class] {
+					a.Class = class
+				}
+			}
+			knownAssets[symbol] = a
+		}
+	}
+
+	if !isClass(a.Class) {
+		return fmt.Errorf("asset %q has invalid class %q, should be one of %v", a.Symbol, a.Class, GetAssetClasses())
+	}
+
+	return nil
+}
+
+func validateComponents(a Asset) error {
+	if len(a.Components) == 0 {
+		return nil
+	}
+
+	total := 0.0
+	for _, component := range a.Components {
+		if (component.Symbol == "") == (component.Class == "") {
+			return fmt.Errorf("component of %q needs either a symbol or a class", a.Symbol)
+		}
+		if component.Class != "" && !isClass(component.Class) {
+			return fmt.Errorf("component of %q has invalid class %q, should be one of %v", a.Symbol, component.Class, GetAssetClasses())
+		}
+		if component.Symbol == a.Symbol {
+			return fmt.Errorf("asset %q is a component of itself", a.Symbol)
+		}
+		if component.Weight <= 0 {
+			return fmt.Errorf("component of %q has a non positive weight", a.Symbol)
+		}
+		total += component.Weight
+	}
+
+	if math.Abs(total-1) > weightTolerance {
+		return fmt.Errorf("components of %q add up to %f, should be 1", a.Symbol, total)
+	}
+
+	return nil
+}
+
+// Assets returns every known asset sorted by symbol
+func Assets() []Asset {
+	assets := make([]Asset, 0, len(knownAssets))
+	for _, a := range knownAssets {
+		assets = append(assets, a)
+	}
+
+	sort.Slice(assets, func(i, j int) bool {
+		return assets[i].Symbol < assets[j].Symbol
+	})
+
+	return assets
+}
+
+// SameGroup reports whether both symbols are known assets of the same equivalence group
+func SameGroup(symbol, other string) bool {
+	a, err := GetAsset(symbol)
+	if err != nil || a.Group == "" {
+		return false
+	}
+
+	b, err := GetAsset(other)
+	if err != nil {
+		return false
+	}
+
+	return a.Group == b.Group
+}
+
+// merge overrides the fields of known with the ones set in override
+func merge(known, override Asset) Asset {
+	if override.Name != "" {
+		known.Name = override.Name
+	}
+	if override.Class != "" {
+		known.Class = override.Class
+	}
+	if override.SubClass != "" {
+		known.SubClass = override.SubClass
+	}
+	if override.ExpenseRatio != 0 {
+		known.ExpenseRatio = override.ExpenseRatio
+	}
+	if override.Group != "" {
+		known.Group = override.Group
+	}
+	if override.Substitutes != nil {
+		known.Substitutes = override.Substitutes
+	}
+	if override.Components != nil {
+		known.Components = override.Components
+	}
+
+	return known
+}
+
+func isClass(class Class) bool {
+	for _, c := range GetAssetClasses() {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
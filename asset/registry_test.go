@@ -0,0 +1,101 @@
+package asset
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// restoreKnownAssets undoes whatever a test registered
+func restoreKnownAssets() func() {
+	saved := map[string]Asset{}
+	for symbol, a := range knownAssets {
+		saved[symbol] = a
+	}
+
+	return func() {
+		knownAssets = saved
+	}
+}
+
+func writeAssetsFile(t *testing.T, dir, contents string) string {
+	path := filepath.Join(dir, "assets.json")
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadFileMergesOverKnownAssets(t *testing.T) {
+	defer restoreKnownAssets()()
+
+	dir, err := ioutil.TempDir("", "assets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := writeAssetsFile(t, dir, `{"assets": [
+		{"symbol": "VTI", "expenseRatio": 0.0003},
+		{"symbol": "VNQ", "class": "Equity"},
+		{"symbol": "FZROX", "name": "Fidelity ZERO Total Market Index Fund", "class": "Equity", "subClass": "Domestic"}
+	]}`)
+	if err := LoadFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the expense ratio is set, the rest of the built-in is kept
+	vti, err := GetAsset("VTI")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vti.ExpenseRatio != 0.0003 || vti.Name != "Vanguard Total Stock Market ETF" || vti.Class != Equity || vti.SubClass != Domestic {
+		t.Errorf("expected the expense ratio to be merged over the built-in VTI, got %+v", vti)
+	}
+
+	vnq, err := GetAsset("VNQ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vnq.Class != Equity {
+		t.Errorf("expected VNQ to be overridden to Equity, got %+v", vnq)
+	}
+
+	if _, err := GetAsset("FZROX"); err != nil {
+		t.Errorf("expected FZROX to be added: %v", err)
+	}
+}
+
+func TestLoadFileErrors(t *testing.T) {
+	defer restoreKnownAssets()()
+
+	dir, err := ioutil.TempDir("", "assets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name     string
+		contents string
+		err      string
+	}{
+		{name: "malformed", contents: `{"assets": [{"symbol": "VTI"`, err: "failed to parse assets file"},
+		{name: "invalid class", contents: `{"assets": [{"symbol": "VTI", "class": "Crypto"}]}`, err: `invalid class "Crypto"`},
+		{name: "no symbol", contents: `{"assets": [{"name": "Mystery Fund", "class": "Equity"}]}`, err: "has no symbol"},
+		{name: "unbalanced components", contents: `{"assets": [{"symbol": "BLEND", "components": [{"symbol": "VTI", "weight": 0.5}]}]}`, err: "add up to"},
+	}
+
+	for _, test := range tests {
+		err := LoadFile(writeAssetsFile(t, dir, test.contents))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: expected error %q, got %v", test.name, test.err, err)
+		}
+	}
+
+	if vti, _ := GetAsset("VTI"); vti.Class != Equity {
+		t.Errorf("expected the invalid class to be rejected, VTI is %+v", vti)
+	}
+}
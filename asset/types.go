@@ -36,63 +36,192 @@ const (
 
 // Asset holds information for a specific asset
 type Asset struct {
-	Symbol   string
-	Class    Class
-	SubClass SubClass
+	Symbol       string   `json:"symbol"`
+	Name         string   `json:"name,omitempty"`
+	Class        Class    `json:"class"`
+	SubClass     SubClass `json:"subClass,omitempty"`
+	ExpenseRatio float64  `json:"expenseRatio,omitempty"`
+
+	// Group names a set of interchangeable funds, e.g. the same index at
+	// different brokers. Held funds count towards a plan's asset in their group.
+	Group string `json:"group,omitempty"`
+
+	// Substitutes are similar but not substantially identical funds to swap
+	// into when harvesting a loss, in the same class and subclass. They're set
+	// in the assets file, none are built in.
+	Substitutes []string `json:"substitutes,omitempty"`
+
+	// Components break a blended fund, e.g. a target-date or balanced fund,
+	// down into other assets or classes. Its class totals are looked through
+	// to the components, Class is only the largest of them.
+	Components []Component `json:"components,omitempty"`
+}
+
+// Component is a weighted part of a blended fund, either a known asset by
+// symbol or a class and optional subclass
+type Component struct {
+	Symbol   string   `json:"symbol,omitempty"`
+	Class    Class    `json:"class,omitempty"`
+	SubClass SubClass `json:"subClass,omitempty"`
+	Weight   float64  `json:"weight"`
 }
 
 var (
 	knownAssets = map[string]Asset{
 		"VTI": {
 			Symbol:   "VTI",
+			Name:     "Vanguard Total Stock Market ETF",
 			Class:    Equity,
 			SubClass: Domestic,
 		},
 		"VEA": {
 			Symbol:   "VEA",
+			Name:     "Vanguard FTSE Developed Markets ETF",
 			Class:    Equity,
 			SubClass: International,
 		},
 		"VWO": {
 			Symbol:   "VWO",
+			Name:     "Vanguard FTSE Emerging Markets ETF",
 			Class:    Equity,
 			SubClass: EmergingMarkets,
 		},
 		"TLT": {
 			Symbol:   "TLT",
+			Name:     "iShares 20+ Year Treasury Bond ETF",
 			Class:    Bond,
 			SubClass: LongTermTreasury,
 		},
 		"IEF": {
 			Symbol:   "IEF",
+			Name:     "iShares 7-10 Year Treasury Bond ETF",
 			Class:    Bond,
 			SubClass: MediumTermTreasury,
 		},
 		"DBC": {
 			Symbol:   "DBC",
+			Name:     "Invesco DB Commodity Index Tracking Fund",
 			Class:    Comodity,
 			SubClass: Index,
 		},
 		"GLD": {
 			Symbol:   "GLD",
+			Name:     "SPDR Gold Shares",
 			Class:    Comodity,
 			SubClass: Gold,
 		},
 		"VNQ": {
 			Symbol:   "VNQ",
+			Name:     "Vanguard Real Estate ETF",
 			Class:    RealEstate,
 			SubClass: Reits,
 		},
 		"VTIP": {
 			Symbol:   "VTIP",
+			Name:     "Vanguard Short-Term Inflation-Protected Securities ETF",
 			Class:    Bond,
 			SubClass: InflationProtectedSecurities,
 		},
 		"VGIT": {
 			Symbol:   "VGIT",
+			Name:     "Vanguard Intermediate-Term Treasury ETF",
+			Class:    Bond,
+			SubClass: MediumTermTreasury,
+		},
+		"VBIAX": {
+			Symbol:   "VBIAX",
+			Name:     "Vanguard Balanced Index Fund Admiral Shares",
+			Class:    Equity,
+			SubClass: Domestic,
+			Components: []Component{
+				{Symbol: "VTI", Weight: .6},
+				{Class: Bond, Weight: .4},
+			},
+		},
+		"SCHB": {
+			Symbol:   "SCHB",
+			Name:     "Schwab U.S. Broad Market ETF",
+			Class:    Equity,
+			SubClass: Domestic,
+		},
+		"ITOT": {
+			Symbol:   "ITOT",
+			Name:     "iShares Core S&P Total U.S. Stock Market ETF",
+			Class:    Equity,
+			SubClass: Domestic,
+		},
+		"IEFA": {
+			Symbol:   "IEFA",
+			Name:     "iShares Core MSCI EAFE ETF",
+			Class:    Equity,
+			SubClass: International,
+		},
+		"SCHF": {
+			Symbol:   "SCHF",
+			Name:     "Schwab International Equity ETF",
+			Class:    Equity,
+			SubClass: International,
+		},
+		"IEMG": {
+			Symbol:   "IEMG",
+			Name:     "iShares Core MSCI Emerging Markets ETF",
+			Class:    Equity,
+			SubClass: EmergingMarkets,
+		},
+		"SCHE": {
+			Symbol:   "SCHE",
+			Name:     "Schwab Emerging Markets Equity ETF",
+			Class:    Equity,
+			SubClass: EmergingMarkets,
+		},
+		"VGLT": {
+			Symbol:   "VGLT",
+			Name:     "Vanguard Long-Term Treasury ETF",
+			Class:    Bond,
+			SubClass: LongTermTreasury,
+		},
+		"SPTL": {
+			Symbol:   "SPTL",
+			Name:     "SPDR Portfolio Long Term Treasury ETF",
+			Class:    Bond,
+			SubClass: LongTermTreasury,
+		},
+		"SCHR": {
+			Symbol:   "SCHR",
+			Name:     "Schwab Intermediate-Term U.S. Treasury ETF",
 			Class:    Bond,
 			SubClass: MediumTermTreasury,
 		},
+		"STIP": {
+			Symbol:   "STIP",
+			Name:     "iShares 0-5 Year TIPS Bond ETF",
+			Class:    Bond,
+			SubClass: InflationProtectedSecurities,
+		},
+		"PDBC": {
+			Symbol:   "PDBC",
+			Name:     "Invesco Optimum Yield Diversified Commodity Strategy No K-1 ETF",
+			Class:    Comodity,
+			SubClass: Index,
+		},
+		"IAU": {
+			Symbol:   "IAU",
+			Name:     "iShares Gold Trust",
+			Class:    Comodity,
+			SubClass: Gold,
+		},
+		"SCHH": {
+			Symbol:   "SCHH",
+			Name:     "Schwab U.S. REIT ETF",
+			Class:    RealEstate,
+			SubClass: Reits,
+		},
+		"USRT": {
+			Symbol:   "USRT",
+			Name:     "iShares Core U.S. REIT ETF",
+			Class:    RealEstate,
+			SubClass: Reits,
+		},
 	}
 )
 
@@ -6,4 +6,5 @@ require (
 	github.com/360EntSecGroup-Skylar/excelize v1.4.1 // indirect
 	github.com/gocarina/gocsv v0.0.0-20200324105150-9eab084b7a45
 	golang.org/x/crypto v0.0.0-20200429183012-4b2356b1ed79 // indirect
+	gopkg.in/yaml.v2 v2.4.0
 )
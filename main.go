@@ -4,55 +4,549 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/samkreter/portfoli/allocations"
+	"github.com/samkreter/portfoli/asset"
+	"github.com/samkreter/portfoli/pkg/config"
 	"github.com/samkreter/portfoli/pkg/fidelity"
+	"github.com/samkreter/portfoli/pkg/importer"
+	"github.com/samkreter/portfoli/pkg/money"
+	_ "github.com/samkreter/portfoli/pkg/ofx"
+	_ "github.com/samkreter/portfoli/pkg/schwab"
+	_ "github.com/samkreter/portfoli/pkg/vanguard"
+	"github.com/samkreter/portfoli/portfolio"
 )
 
 type command string
 
+const (
+	// driftExceededExitCode is returned by the drift command when a drift is over its threshold
+	driftExceededExitCode = 3
+	// rowsRejectedExitCode is returned by the validate-import command when rows were dropped
+	rowsRejectedExitCode = 4
+
+	// minLotCoverage is the smallest part of a sale worth reporting as not covered by lots
+	minLotCoverage = money.Cent
+
+	// maxRateAge is how old FX rates can be before a warning is logged
+	maxRateAge = 7 * 24 * time.Hour
+)
+
+// washSaleMode is what to do with trades that would be wash sales
+type washSaleMode string
+
+const (
+	washSaleFlag  = washSaleMode("flag")
+	washSaleBlock = washSaleMode("block")
+	washSaleOff   = washSaleMode("off")
+)
+
+func washSaleModes() []washSaleMode {
+	return []washSaleMode{washSaleFlag, washSaleBlock, washSaleOff}
+}
+
+// stringSlice is a flag that can be repeated
+type stringSlice []string
+
+func (s *stringSlice) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringSlice) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
 func main() {
-	filename := flag.String("inputfile", "", "filepath to the fidelity csv (defaults to ~/Downloads/portfoli.csv)")
+	var inputFiles stringSlice
+	flag.Var(&inputFiles, "inputfile", "filepath or glob of a brokerage export, repeat to combine exports (defaults to the latest ~/Downloads/Portfolio_Position*)")
+	brokerName := flag.String("broker", "", "importer to parse the input file with "+fmt.Sprint(importer.Names())+" (detected from the file by default)")
+
+	assetAllocationName := flag.String("allocation-name", "Swensen", "Name of the asset allocation to use (see -c plans)")
+	flag.StringVar(assetAllocationName, "a", *assetAllocationName, "Name of the asset allocation to use (see -c plans)")
+
+	planFile := flag.String("plan-file", "", "filepath to a JSON or YAML allocation plan, overrides -allocation-name")
+
+	strategy := flag.String("strategy", string(allocations.BuyOnly), fmt.Sprintf("rebalance strategy to use %v", allocations.Strategies()))
+	cash := new(money.Money)
+	flag.Var(cash, "cash", "cash to invest with the hybrid strategy and the contribute command, or to raise with the withdraw command")
+
+	sharePrecision := flag.Int("share-precision", 0, "decimal places of the share quantities in the orders command, 0 for whole shares")
+
+	absoluteBand := flag.Float64("abs-band", .05, "default absolute tolerance band in percentage points of the plan for the bands command, .05 is ±5 points")
+	relativeBand := flag.Float64("rel-band", .25, "default relative tolerance band as a fraction of the target for the bands command, .25 is ±25% of the target")
+	bandTarget := flag.String("band-target", string(allocations.ToTarget), "where the bands command trades breaching assets back to [target, edge]")
+
+	driftThreshold := flag.Float64("drift-threshold", .05, "percentage points of drift that make the drift command exit non-zero, .05 is 5 points, 0 to disable")
+	relativeDriftThreshold := flag.Float64("relative-drift-threshold", 0, "relative drift that makes the drift command exit non-zero, .25 is 25% of the target, 0 to disable")
+
+	accountsFile := flag.String("accounts-file", "", "filepath to the JSON account tax types for the location command (defaults to <config dir>/portfoli/accounts.json)")
+
+	assetsFile := flag.String("assets-file", "", "filepath to the JSON assets merged over the built-in ones (defaults to <config dir>/portfoli/assets.json)")
 
-	assetAllocationName := flag.String("allocation-name", "Swensen", "Name of the asset allocation to use [Swensen, AllWeather]")
-	flag.StringVar(assetAllocationName, "a", *assetAllocationName, "Name of the asset allocation to use [Swensen, AllWeather]")
+	baseCurrency := money.USD
+	flag.Var(&baseCurrency, "base-currency", "ISO currency every value is converted to before allocating")
+
+	fxFile := flag.String("fx-file", "", "filepath to the JSON FX rates used to convert to the base currency (defaults to <config dir>/portfoli/fx.json)")
+
+	lotMethod := flag.String("lot-method", string(portfolio.HIFO), fmt.Sprintf("how to pick the tax lots to sell when lots are imported %v", portfolio.LotMethods()))
+
+	harvestThreshold := new(money.Money)
+	*harvestThreshold = 1000 * money.Unit
+	flag.Var(harvestThreshold, "harvest-threshold", "smallest unrealized loss the harvest command reports")
+
+	washSale := flag.String("wash-sale", string(washSaleFlag), fmt.Sprintf("what to do with sells at a loss that would be wash sales when history is imported %v", washSaleModes()))
+
+	byAccount := flag.Bool("by-account", false, "also print the per account breakdown of the plan's holdings")
+
+	strict := flag.Bool("strict", false, "fail when a row of an export, a position, tax lot or history transaction, is rejected instead of leaving it out")
 
 	command := flag.String("c", "desired", "the command to use")
 	flag.Parse()
 
-	currPositions, err := fidelity.GetCurrentPositions(*filename)
-	if err != nil {
+	if *sharePrecision < 0 {
+		log.Fatalf("-share-precision can't be negative, got %d", *sharePrecision)
+	}
+
+	if err := loadAssets(*assetsFile); err != nil {
 		log.Fatal(err)
 	}
 
-	// Get desired allocation plan
-	allocationPlan, err := allocations.GetAllocation(*assetAllocationName)
+	if *command == "plans" {
+		if err := printAllocationPlans(); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	currPortfolio, imports, err := importPortfolio(inputFiles, *brokerName)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	// Add current asset positions
-	for idx, allocationAsset := range allocationPlan.Allocations {
-		for _, position := range currPositions {
-			if allocationAsset.Symbol == position.Symbol {
-				allocationPlan.Allocations[idx].CurrValue = position.Current.Value
+	if *command == "validate-import" {
+		if printImportSummary(imports) > 0 {
+			os.Exit(rowsRejectedExitCode)
+		}
+		return
+	}
+
+	rejected := 0
+	for _, result := range imports {
+		for _, diagnostic := range result.Diagnostics {
+			if diagnostic.Severity != importer.Skipped {
+				log.Printf("Warning: %s", diagnostic)
 			}
 		}
+		rejected += result.Count(importer.Rejected)
+	}
+	if *strict && rejected > 0 {
+		log.Fatalf("%d rows were rejected, see -c validate-import", rejected)
 	}
 
-	if err := allocationPlan.UpdateDesiredValues(); err != nil {
+	if err := convertCurrencies(currPortfolio, *fxFile, baseCurrency); err != nil {
 		log.Fatal(err)
 	}
 
+	if *command == "assets" {
+		printAssets(currPortfolio)
+		return
+	}
+
+	// Get desired allocation plan
+	allocationPlan, err := getAllocationPlan(*planFile, *assetAllocationName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Add current asset positions, summed across all accounts
+	symbols := []string{}
+	for _, holding := range currPortfolio.Holdings() {
+		symbols = append(symbols, holding.Symbol)
+	}
+	allocationPlan.AddGroupEquivalents(symbols)
+	allocationPlan.SetCurrValues(currPortfolio.Values())
+
+	// A money market fund the plan holds as an asset isn't cash on hand too
+	planSymbols := []string{}
+	for _, allocationAsset := range allocationPlan.Allocations {
+		planSymbols = append(planSymbols, allocationAsset.Symbols()...)
+	}
+	allocationPlan.SetCash(currPortfolio.CashExcluding(planSymbols))
+
+	exitCode := 0
+	// hasTrades is cleared by the reports that don't compute desired values for the plan
+	hasTrades := true
+	// printResult prints the command's output once the desired values are final
+	var printResult func()
 	switch *command {
 	case "desired":
-		printPlanForReallocation(allocationPlan)
+		err = allocationPlan.Rebalance(allocations.Strategy(*strategy), *cash)
+		printResult = func() { printPlanForReallocation(allocationPlan) }
 	case "classes":
-		printAssetClassPercents(allocationPlan)
+		err = allocationPlan.Rebalance(allocations.Strategy(*strategy), *cash)
+		printResult = func() { printAssetClassPercents(allocationPlan) }
+	case "orders":
+		err = allocationPlan.Rebalance(allocations.Strategy(*strategy), *cash)
+		printResult = func() {
+			orderList := allocationPlan.Orders(currPortfolio.Prices(), currPortfolio.Quantities(), *sharePrecision)
+			printOrders(allocationPlan, orderList)
+		}
+	case "bands":
+		allocationPlan.SetDefaultBands(*absoluteBand, *relativeBand)
+		err = allocationPlan.RebalanceBands(allocations.BandTarget(*bandTarget))
+		printResult = func() { printBands(allocationPlan) }
+	case "drift":
+		hasTrades = false
+		printResult = func() {
+			if printDrift(allocationPlan, *driftThreshold, *relativeDriftThreshold) {
+				exitCode = driftExceededExitCode
+			}
+		}
+	case "levels":
+		hasTrades = false
+		printResult = func() { printLevelDrifts(allocationPlan) }
+	case "location":
+		var taxTypes map[string]portfolio.TaxType
+		taxTypes, err = loadAccountTaxTypes(*accountsFile)
+		if err != nil {
+			break
+		}
+
+		var accountTrades []allocations.AccountTrade
+		var unplaced map[string]money.Money
+		accountTrades, unplaced, err = allocationPlan.Locate(currPortfolio.AccountValues(), currPortfolio.AccountCashExcluding(planSymbols), taxTypes)
+		printResult = func() { printLocation(allocationPlan, accountTrades, unplaced, currPortfolio, taxTypes) }
+	case "harvest":
+		hasTrades = false
+		var taxTypes map[string]portfolio.TaxType
+		taxTypes, err = loadAccountTaxTypes(*accountsFile)
+		printResult = func() {
+			candidates := currPortfolio.HarvestCandidates(*harvestThreshold, taxTypes, time.Now())
+			printHarvestCandidates(allocationPlan, currPortfolio, candidates, washSaleMode(*washSale))
+		}
+	case "cash":
+		hasTrades = false
+		printResult = func() { printCash(allocationPlan, currPortfolio) }
+	case "contribute":
+		err = allocationPlan.Contribute(*cash)
+		printResult = func() { printContribution(allocationPlan) }
+	case "withdraw":
+		err = allocationPlan.Withdraw(*cash)
+		printResult = func() { printWithdrawal(allocationPlan) }
 	default:
 		log.Fatal("Unkown command")
 	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Blocked wash sales are dropped from the plan before anything is printed
+	var washSales []string
+	if hasTrades && len(currPortfolio.Transactions) > 0 {
+		mode := washSaleMode(*washSale)
+		if *command == "location" && mode == washSaleBlock {
+			// The account trades are already placed, so they can only be flagged
+			mode = washSaleFlag
+		}
+		washSales, err = guardWashSales(&allocationPlan, currPortfolio, portfolio.LotMethod(*lotMethod), mode)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	printResult()
+
+	if len(washSales) > 0 {
+		fmt.Println()
+		fmt.Println("Wash sales:")
+		for _, washSale := range washSales {
+			fmt.Printf("\t%s\n", washSale)
+		}
+	}
+
+	if hasTrades && currPortfolio.HasLots() {
+		if err := printLotSales(allocationPlan, currPortfolio, portfolio.LotMethod(*lotMethod)); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *byAccount {
+		printAccountBreakdown(allocationPlan, currPortfolio, hasTrades)
+	}
+
+	os.Exit(exitCode)
+}
+
+// importPortfolio imports every file matched by the input file globs into a
+// single portfolio, the results of each file are returned for their diagnostics
+func importPortfolio(inputFiles []string, brokerName string) (*portfolio.Portfolio, []importer.Result, error) {
+	filenames, err := expandInputFiles(inputFiles)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	currPortfolio := portfolio.New(nil)
+	imports := []importer.Result{}
+	lots := []importer.Lot{}
+	for _, filename := range filenames {
+		result, err := importer.ImportFile(filename, brokerName)
+		if err != nil {
+			return nil, nil, err
+		}
+		imports = append(imports, result)
+
+		currPortfolio.Add(result.Positions...)
+		currPortfolio.AddTransactions(result.Transactions...)
+		lots = append(lots, result.Lots...)
+	}
+
+	// Lots are attached once every file is imported since they usually come in their own export
+	for _, lot := range currPortfolio.AddLots(lots...) {
+		log.Printf("Warning: no position for the %s lot in %q acquired %s", lot.Symbol, lot.Account, lot.Acquired.Format("2006-01-02"))
+	}
+
+	return currPortfolio, imports, nil
+}
+
+// printImportSummary prints what was parsed, skipped and rejected in each
+// file, returning the number of rejected rows
+func printImportSummary(imports []importer.Result) int {
+	parsed, skipped, rejected := 0, 0, 0
+	for idx, result := range imports {
+		if idx > 0 {
+			fmt.Println()
+		}
+
+		fmt.Printf("%s (%s): %d positions, %d lots, %d transactions parsed, %d skipped, %d rejected\n",
+			result.File, result.Importer, len(result.Positions), len(result.Lots), len(result.Transactions),
+			result.Count(importer.Skipped), result.Count(importer.Rejected))
+		for _, diagnostic := range result.Diagnostics {
+			diagnostic.File = ""
+			fmt.Printf("\t%s\n", diagnostic)
+		}
+
+		parsed += len(result.Positions) + len(result.Lots) + len(result.Transactions)
+		skipped += result.Count(importer.Skipped)
+		rejected += result.Count(importer.Rejected)
+	}
+
+	fmt.Printf("Total: %d parsed, %d skipped, %d rejected\n", parsed, skipped, rejected)
+	return rejected
+}
+
+func expandInputFiles(inputFiles []string) ([]string, error) {
+	// get default filename
+	if len(inputFiles) == 0 {
+		filename, err := fidelity.DefaultPositionsFile()
+		if err != nil {
+			return nil, err
+		}
+		fmt.Printf("Using default Fidelity portfoli file: %q\n", filename)
+		return []string{filename}, nil
+	}
+
+	seen := map[string]bool{}
+	filenames := []string{}
+	for _, inputFile := range inputFiles {
+		matches, err := filepath.Glob(inputFile)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match %q", inputFile)
+		}
+
+		for _, match := range matches {
+			if !seen[match] {
+				seen[match] = true
+				filenames = append(filenames, match)
+			}
+		}
+	}
+
+	return filenames, nil
+}
+
+// loadAccountTaxTypes loads the accounts file passed with -accounts-file, or
+// the default one when it exists
+func loadAccountTaxTypes(accountsFile string) (map[string]portfolio.TaxType, error) {
+	if accountsFile != "" {
+		return portfolio.LoadAccounts(accountsFile)
+	}
+
+	accountsFile, err := config.AccountsFile()
+	if err != nil {
+		return nil, err
+	}
+
+	taxTypes, err := portfolio.LoadAccounts(accountsFile)
+	if os.IsNotExist(err) {
+		return map[string]portfolio.TaxType{}, nil
+	}
+	return taxTypes, err
+}
 
+// loadAssets merges the assets file passed with -assets-file, or the default
+// one when it exists, over the built-in assets
+func loadAssets(assetsFile string) error {
+	if assetsFile != "" {
+		return asset.LoadFile(assetsFile)
+	}
+
+	assetsFile, err := config.AssetsFile()
+	if err != nil {
+		return err
+	}
+
+	if err := asset.LoadFile(assetsFile); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// convertCurrencies converts the portfolio to base with the rates in fxFile,
+// the file is only needed when something is held in another currency
+func convertCurrencies(currPortfolio *portfolio.Portfolio, fxFile string, base money.Currency) error {
+	foreign := []money.Currency{}
+	for _, currency := range currPortfolio.Currencies() {
+		if currency != base {
+			foreign = append(foreign, currency)
+		}
+	}
+	if len(foreign) == 0 {
+		return nil
+	}
+
+	if fxFile == "" {
+		var err error
+		fxFile, err = config.FXFile()
+		if err != nil {
+			return err
+		}
+	}
+
+	rates, err := money.LoadRates(fxFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("holdings in %v need FX rates to %s in %q", foreign, base, fxFile)
+		}
+		return err
+	}
+
+	if age := time.Since(rates.AsOf); age > maxRateAge {
+		log.Printf("Warning: FX rates are %d days old", int(age.Hours()/24))
+	}
+
+	if err := currPortfolio.Convert(rates, base); err != nil {
+		return err
+	}
+
+	fmt.Printf("Values in %s, %v converted with FX rates as of %s\n", base, foreign, rates.AsOf.Format("2006-01-02"))
+	return nil
+}
+
+// nativeValue formats the imported value of a position converted from another currency
+func nativeValue(position importer.Position) string {
+	if position.Native == nil {
+		return ""
+	}
+	return fmt.Sprintf(" (%.2f %s)", position.Native.Value, position.Native.Currency)
+}
+
+func getAllocationPlan(planFile, allocationName string) (allocations.AllocationPlan, error) {
+	if planFile != "" {
+		return allocations.LoadPlanFile(planFile)
+	}
+
+	return allocations.GetAllocation(allocationName)
+}
+
+// printAssets lists the known assets and the held symbols that aren't classified
+func printAssets(currPortfolio *portfolio.Portfolio) {
+	for _, a := range asset.Assets() {
+		fmt.Printf("%s %s Class: %s", a.Symbol, a.Name, a.Class)
+		if a.SubClass != "" {
+			fmt.Printf("/%s", a.SubClass)
+		}
+		if a.ExpenseRatio != 0 {
+			fmt.Printf(" Expense ratio: %.2f%%", a.ExpenseRatio*100)
+		}
+		if a.Group != "" {
+			fmt.Printf(" Group: %s", a.Group)
+		}
+		if len(a.Components) > 0 {
+			components := []string{}
+			for _, component := range a.Components {
+				name := component.Symbol
+				if name == "" {
+					name = string(component.Class)
+					if component.SubClass != "" {
+						name += "/" + string(component.SubClass)
+					}
+				}
+				components = append(components, fmt.Sprintf("%.2f%% %s", component.Weight*100, name))
+			}
+			fmt.Printf(" Components: %s", strings.Join(components, ", "))
+		}
+		if holding, ok := currPortfolio.Holding(a.Symbol); ok {
+			fmt.Printf(" Held: %.2f", holding.Value)
+		}
+		fmt.Println()
+	}
+
+	unclassified := unclassifiedHoldings(currPortfolio)
+	if len(unclassified) == 0 {
+		return
+	}
+
+	fmt.Println()
+	fmt.Println("Unclassified holdings, add them to the assets file:")
+	for _, holding := range unclassified {
+		fmt.Printf("\t%s Value: %.2f\n", holding.Symbol, holding.Value)
+	}
+}
+
+// unclassifiedHoldings returns the holdings, other than cash, missing from the asset registry
+func unclassifiedHoldings(currPortfolio *portfolio.Portfolio) []*portfolio.Holding {
+	unclassified := []*portfolio.Holding{}
+	for _, holding := range currPortfolio.Holdings() {
+		// Cash isn't an asset of the plan, see the cash command
+		if holding.Accounts[0].IsCash() {
+			continue
+		}
+		if _, err := asset.GetAsset(holding.Symbol); err != nil {
+			unclassified = append(unclassified, holding)
+		}
+	}
+
+	return unclassified
+}
+
+func printAllocationPlans() error {
+	names, err := allocations.ListAllocations()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		plan, err := allocations.GetAllocation(name)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(plan.Name, ": ", plan.Description)
+		for _, allocationAsset := range plan.Allocations {
+			fmt.Printf("\t%s %g%%\n", allocationAsset.Symbol, math.Round(allocationAsset.DesiredPercent*10000)/100)
+		}
+	}
+
+	return nil
 }
 
 func printAssetClassPercents(allocationPlan allocations.AllocationPlan) {
@@ -63,12 +557,471 @@ func printAssetClassPercents(allocationPlan allocations.AllocationPlan) {
 	}
 }
 
+func printAccountBreakdown(allocationPlan allocations.AllocationPlan, currPortfolio *portfolio.Portfolio, withTrades bool) {
+	fmt.Println()
+	fmt.Println("By account:")
+	for _, allocationAsset := range allocationPlan.Allocations {
+		for _, symbol := range allocationAsset.Symbols() {
+			holding, ok := currPortfolio.Holding(symbol)
+			if !ok {
+				continue
+			}
+
+			fmt.Println(symbol, "Total Value: ", holding.Value)
+			for _, position := range holding.Accounts {
+				fmt.Printf("\t%s Quantity: %g Value: %g%s\n", position.Account, position.Quantity, position.Value, nativeValue(position))
+			}
+		}
+	}
+
+	if !withTrades {
+		return
+	}
+
+	accountTrades := allocationPlan.AccountTrades(currPortfolio.AccountValues())
+	if len(accountTrades) == 0 {
+		return
+	}
+
+	fmt.Println()
+	fmt.Println("Trades by account:")
+	for _, trade := range accountTrades {
+		account := trade.Account
+		if account == "" {
+			account = "(not held)"
+		}
+
+		action := "Buy"
+		if trade.Amount < 0 {
+			action = "Sell"
+		}
+		fmt.Printf("\t%s %s %s %.2f\n", account, action, trade.Symbol, trade.Amount.Abs())
+	}
+}
+
 func printPlanForReallocation(allocationPlan allocations.AllocationPlan) {
-	var diff float64
+	var diff money.Money
 	for _, allocationAsset := range allocationPlan.Allocations {
 		diff = allocationAsset.DesiredValue - allocationAsset.CurrValue
 		fmt.Println(allocationAsset.Symbol, "Curr Value: ", allocationAsset.CurrValue, "Desired: ", allocationAsset.DesiredValue, "Difference: ", diff)
 	}
 
-	fmt.Println("Cash required: ", allocationPlan.GetDesiredTotalValue()-allocationPlan.GetCurrTotalVal())
+	printCashOnHand(allocationPlan)
+	fmt.Println("Cash required: ", allocationPlan.CashRequired())
+
+	printTrades(allocationPlan.Trades())
+}
+
+// printCashOnHand prints the held cash the plan invests, if it has any or keeps a cash target
+func printCashOnHand(allocationPlan allocations.AllocationPlan) {
+	if allocationPlan.Cash == 0 && allocationPlan.CashTarget() == 0 {
+		return
+	}
+
+	fmt.Printf("Cash on hand: %.2f Target: %.2f Available: %.2f\n", allocationPlan.Cash, allocationPlan.CashTarget(), allocationPlan.AvailableCash())
+}
+
+func printCash(allocationPlan allocations.AllocationPlan, currPortfolio *portfolio.Portfolio) {
+	accountCash := currPortfolio.AccountCash()
+	for _, account := range currPortfolio.Accounts() {
+		cash, ok := accountCash[account]
+		if !ok {
+			continue
+		}
+
+		fmt.Printf("%s Cash: %.2f\n", account, cash)
+		for _, position := range currPortfolio.AccountPositions(account) {
+			if position.IsCash() {
+				fmt.Printf("\t%s %s: %.2f%s\n", position.Symbol, position.Description, position.Value, nativeValue(position))
+			}
+		}
+	}
+
+	fmt.Printf("Total cash: %.2f\n", currPortfolio.Cash())
+	printCashOnHand(allocationPlan)
+}
+
+func printContribution(allocationPlan allocations.AllocationPlan) {
+	desiredTotal := allocationPlan.GetDesiredTotalValue()
+	for _, allocationAsset := range allocationPlan.Allocations {
+		buy := allocationAsset.DesiredValue - allocationAsset.CurrValue
+		fmt.Printf("%s Buy: %.2f Percent: %.2f%% -> %.2f%% Target: %.2f%%\n",
+			allocationAsset.Symbol, buy, allocationAsset.CurrPercent*100,
+			allocationAsset.DesiredValue.Ratio(desiredTotal)*100, allocationAsset.DesiredPercent*100)
+	}
+
+	fmt.Printf("Invested: %.2f\n", desiredTotal-allocationPlan.GetCurrTotalVal())
+}
+
+func printWithdrawal(allocationPlan allocations.AllocationPlan) {
+	desiredTotal := allocationPlan.GetDesiredTotalValue()
+	for _, allocationAsset := range allocationPlan.Allocations {
+		sell := allocationAsset.CurrValue - allocationAsset.DesiredValue
+
+		percent := allocationAsset.DesiredValue.Ratio(desiredTotal)
+
+		fmt.Printf("%s Sell: %.2f Percent: %.2f%% -> %.2f%% Target: %.2f%% Drift: %+.2f\n",
+			allocationAsset.Symbol, sell, allocationAsset.CurrPercent*100, percent*100,
+			allocationAsset.DesiredPercent*100, (percent-allocationAsset.DesiredPercent)*100)
+	}
+
+	fmt.Printf("Withdrawn: %.2f\n", allocationPlan.GetCurrTotalVal()-desiredTotal)
+}
+
+func printOrders(allocationPlan allocations.AllocationPlan, orderList allocations.OrderList) {
+	fmt.Println("Orders:")
+	if len(orderList.Orders) == 0 {
+		fmt.Println("\tNone")
+	}
+
+	cashRequired := money.Money(0)
+	for _, order := range orderList.Orders {
+		cashRequired += order.Amount()
+		fmt.Printf("\t%s %g %s @ %.2f = %.2f\n", order.Action(), math.Abs(order.Shares), order.Symbol, order.Price, order.Amount().Abs())
+	}
+
+	for _, trade := range orderList.Unpriced {
+		cashRequired += trade.Amount
+		fmt.Printf("\tNo price for %s, %s %.2f by amount\n", trade.Symbol, trade.Action(), trade.Amount.Abs())
+	}
+
+	printCashOnHand(allocationPlan)
+	fmt.Printf("Cash required: %.2f Leftover from rounding: %.2f\n", cashRequired-allocationPlan.AvailableCash(), orderList.LeftoverCash)
+
+	valuesAfter := allocationPlan.ValuesAfter(orderList)
+	totalAfter := money.Money(0)
+	for _, value := range valuesAfter {
+		totalAfter += value
+	}
+	if totalAfter == 0 {
+		return
+	}
+
+	fmt.Println()
+	fmt.Println("Drift after orders:")
+	for _, allocationAsset := range allocationPlan.Allocations {
+		percent := valuesAfter[allocationAsset.Symbol].Ratio(totalAfter)
+		fmt.Printf("\t%s %.2f%% Target: %.2f%% Drift: %+.2f\n", allocationAsset.Symbol, percent*100,
+			allocationAsset.DesiredPercent*100, (percent-allocationAsset.DesiredPercent)*100)
+	}
+}
+
+func printBands(allocationPlan allocations.AllocationPlan) {
+	breaching := map[string]bool{}
+	for _, allocationAsset := range allocationPlan.Breaches() {
+		breaching[allocationAsset.Symbol] = true
+	}
+
+	currTotal := allocationPlan.GetCurrTotalVal()
+	for _, allocationAsset := range allocationPlan.Allocations {
+		status := "ok"
+		if breaching[allocationAsset.Symbol] {
+			status = "BREACH"
+		}
+
+		percent := allocationAsset.CurrValue.Ratio(currTotal)
+
+		fmt.Printf("%s %.2f%% Target: %.2f%% Band: ±%.2f %s\n", allocationAsset.Symbol, percent*100,
+			allocationAsset.DesiredPercent*100, allocationAsset.Band()*100, status)
+	}
+
+	if len(breaching) == 0 {
+		fmt.Println("All assets are within their bands")
+		return
+	}
+
+	printTrades(allocationPlan.Trades())
+
+	netCash := allocationPlan.GetDesiredTotalValue() - currTotal
+	if netCash >= 0 {
+		fmt.Printf("Cash required: %.2f\n", netCash)
+	} else {
+		fmt.Printf("Cash freed: %.2f\n", -netCash)
+	}
+}
+
+// printDrift prints the drift of every symbol and asset class, returning whether any exceeds the thresholds
+func printDrift(allocationPlan allocations.AllocationPlan, pointsThreshold, relativeThreshold float64) bool {
+	exceeded := false
+	printDrifts := func(drifts []allocations.Drift) {
+		for _, drift := range drifts {
+			status := "ok"
+			if drift.Exceeds(pointsThreshold, relativeThreshold) {
+				status = "EXCEEDED"
+				exceeded = true
+			}
+
+			relative := "n/a"
+			if !math.IsInf(drift.Relative(), 0) {
+				relative = fmt.Sprintf("%+.2f%%", drift.Relative()*100)
+			}
+
+			fmt.Printf("\t%s %.2f%% Target: %.2f%% Drift: %+.2f Relative: %s %s\n", drift.Name,
+				drift.CurrPercent*100, drift.DesiredPercent*100, drift.Points()*100, relative, status)
+		}
+	}
+
+	fmt.Println("Symbols:")
+	printDrifts(allocationPlan.SymbolDrifts())
+	fmt.Println("Classes:")
+	printDrifts(allocationPlan.ClassDrifts())
+
+	return exceeded
+}
+
+func printLevelDrifts(allocationPlan allocations.AllocationPlan) {
+	for _, drift := range allocationPlan.LevelDrifts() {
+		fmt.Printf("%s%s %.2f%% Target: %.2f%% Drift: %+.2f\n", strings.Repeat("\t", drift.Level), drift.Name,
+			drift.CurrPercent*100, drift.DesiredPercent*100, drift.Points()*100)
+	}
+}
+
+func printLocation(allocationPlan allocations.AllocationPlan, accountTrades []allocations.AccountTrade, unplaced map[string]money.Money, currPortfolio *portfolio.Portfolio, taxTypes map[string]portfolio.TaxType) {
+	for _, account := range currPortfolio.Accounts() {
+		taxType, ok := taxTypes[account]
+		if !ok {
+			log.Printf("Warning: no tax type for account %q, treating it as %s", account, portfolio.Taxable)
+			taxType = portfolio.Taxable
+		}
+
+		fmt.Printf("%s (%s):\n", account, taxType)
+		traded := false
+		for _, trade := range accountTrades {
+			if trade.Account != account {
+				continue
+			}
+
+			action := "Buy"
+			if trade.Amount < 0 {
+				action = "Sell"
+			}
+			fmt.Printf("\t%s %s %.2f\n", action, trade.Symbol, trade.Amount.Abs())
+			traded = true
+		}
+		if !traded {
+			fmt.Println("\tNo trades")
+		}
+	}
+
+	fmt.Println()
+	fmt.Println("Household:")
+	for _, allocationAsset := range allocationPlan.Allocations {
+		fmt.Printf("\t%s Curr Value: %.2f Desired: %.2f\n", allocationAsset.Symbol, allocationAsset.CurrValue, allocationAsset.DesiredValue)
+	}
+
+	// The accounts are full, whatever is left has to come from new money
+	for _, allocationAsset := range allocationPlan.Allocations {
+		if amount, ok := unplaced[allocationAsset.Symbol]; ok {
+			log.Printf("Warning: %.2f of %s didn't fit in any account and isn't placed", amount, allocationAsset.Symbol)
+		}
+	}
+}
+
+// substantiallyIdentical returns the symbol followed by the funds the plan treats as interchangeable with it
+func substantiallyIdentical(allocationPlan allocations.AllocationPlan, symbol string) []string {
+	for _, allocationAsset := range allocationPlan.Allocations {
+		if allocationAsset.HasSymbol(symbol) {
+			symbols := []string{symbol}
+			for _, s := range allocationAsset.Symbols() {
+				if s != symbol {
+					symbols = append(symbols, s)
+				}
+			}
+			return symbols
+		}
+	}
+
+	return []string{symbol}
+}
+
+// guardWashSales checks the plan's sells at a loss against the imported history
+// and describes the ones that would be wash sales. In block mode their sells
+// are dropped from the plan.
+func guardWashSales(allocationPlan *allocations.AllocationPlan, currPortfolio *portfolio.Portfolio, lotMethod portfolio.LotMethod, mode washSaleMode) ([]string, error) {
+	switch mode {
+	case washSaleOff:
+		return nil, nil
+	case washSaleFlag, washSaleBlock:
+	default:
+		return nil, fmt.Errorf("invalid wash sale mode: %s", mode)
+	}
+
+	now := time.Now()
+	prices := currPortfolio.Prices()
+
+	washSales := []string{}
+	for _, trade := range allocationPlan.Trades() {
+		if trade.IsBuy() {
+			continue
+		}
+		symbols := substantiallyIdentical(*allocationPlan, trade.Symbol)
+
+		var gain money.Money
+		var ok bool
+		if currPortfolio.HasLots() {
+			estimate, err := currPortfolio.SelectLots(symbols, -trade.Amount, prices, lotMethod, now)
+			if err != nil {
+				log.Printf("Warning: can't estimate the gain of selling %s from its lots: %v", trade.Symbol, err)
+			}
+			gain, ok = estimate.ShortTermGain+estimate.LongTermGain, err == nil && len(estimate.Sales) > 0
+		}
+		if !ok {
+			// Without priced lots the cost basis of the positions is the next best estimate
+			gain, ok = currPortfolio.UnrealizedGain(symbols, -trade.Amount)
+		}
+		if !ok || gain >= 0 {
+			continue
+		}
+
+		check := currPortfolio.CheckWashSale(symbols, now)
+		if !check.IsWashSale() {
+			continue
+		}
+
+		action := "flagged"
+		if mode == washSaleBlock {
+			action = "blocked"
+			if cut := allocationPlan.Hold(trade.Symbol); cut > 0 {
+				action = fmt.Sprintf("blocked (buys cut by %.2f)", cut)
+			}
+		}
+
+		purchase := check.LatestPurchase()
+		washSales = append(washSales, fmt.Sprintf("Sell %s %.2f at a loss of %.2f %s: %s bought %g %s on %s",
+			trade.Symbol, -trade.Amount, gain, action, purchase.Account, purchase.Quantity, purchase.Symbol, purchase.Date.Format("2006-01-02")))
+	}
+
+	return washSales, nil
+}
+
+func printLotSales(allocationPlan allocations.AllocationPlan, currPortfolio *portfolio.Portfolio, lotMethod portfolio.LotMethod) error {
+	// Sales of an allocation can come from any of its equivalent funds
+	allocationSymbols := map[string][]string{}
+	for _, allocationAsset := range allocationPlan.Allocations {
+		allocationSymbols[allocationAsset.Symbol] = allocationAsset.Symbols()
+	}
+	prices := currPortfolio.Prices()
+
+	printed := false
+	for _, trade := range allocationPlan.Trades() {
+		if trade.IsBuy() {
+			continue
+		}
+		if !printed {
+			fmt.Println()
+			fmt.Printf("Lots to sell (%s):\n", lotMethod)
+			printed = true
+		}
+
+		symbols, ok := allocationSymbols[trade.Symbol]
+		if !ok {
+			symbols = []string{trade.Symbol}
+		}
+
+		estimate, err := currPortfolio.SelectLots(symbols, -trade.Amount, prices, lotMethod, time.Now())
+		if err != nil {
+			return err
+		}
+		for _, symbol := range estimate.Unpriced {
+			log.Printf("Warning: no price for %s, its lots are left out of the %s sale", symbol, trade.Symbol)
+		}
+
+		fmt.Printf("%s Sell %.2f Short-term gain: %.2f Long-term gain: %.2f\n", trade.Symbol, -trade.Amount, estimate.ShortTermGain, estimate.LongTermGain)
+		for _, sale := range estimate.Sales {
+			term := "short"
+			if sale.LongTerm {
+				term = "long"
+			}
+			fmt.Printf("\t%s acquired %s %g shares @ %.2f Gain: %.2f (%s-term)\n", sale.Lot.Account, sale.Lot.Acquired.Format("2006-01-02"),
+				math.Round(sale.Shares*10000)/10000, sale.Lot.CostPerShare, sale.Gain, term)
+		}
+		if estimate.Uncovered >= minLotCoverage {
+			fmt.Printf("\t%.2f not covered by imported lots\n", estimate.Uncovered)
+		}
+	}
+
+	return nil
+}
+
+func printHarvestCandidates(allocationPlan allocations.AllocationPlan, currPortfolio *portfolio.Portfolio, candidates []portfolio.HarvestCandidate, mode washSaleMode) {
+	if len(candidates) == 0 {
+		fmt.Println("No losses to harvest")
+		return
+	}
+
+	totalLoss := money.Money(0)
+	for _, candidate := range candidates {
+		fmt.Printf("%s in %s Loss: %.2f Value: %.2f\n", candidate.Symbol, candidate.Account, candidate.Loss, candidate.Value)
+
+		if mode != washSaleOff && len(currPortfolio.Transactions) > 0 {
+			check := currPortfolio.CheckWashSale(substantiallyIdentical(allocationPlan, candidate.Symbol), time.Now())
+			for _, purchase := range check.Purchases {
+				fmt.Printf("\tWash sale: %s bought %g %s on %s\n", purchase.Account, purchase.Quantity, purchase.Symbol, purchase.Date.Format("2006-01-02"))
+			}
+			if check.IsWashSale() && mode == washSaleBlock {
+				fmt.Println("\tBlocked")
+				continue
+			}
+			fmt.Printf("\tDon't buy %s again before %s\n", candidate.Symbol, check.RepurchaseAfter.Format("2006-01-02"))
+		}
+		totalLoss += candidate.Loss
+
+		for _, sale := range candidate.Lots {
+			fmt.Printf("\tLot acquired %s %g shares @ %.2f Loss: %.2f\n", sale.Lot.Acquired.Format("2006-01-02"),
+				math.Round(sale.Shares*10000)/10000, sale.Lot.CostPerShare, sale.Gain)
+		}
+
+		held, err := asset.GetAsset(candidate.Symbol)
+		if err != nil {
+			fmt.Printf("\tUnknown asset, no substitute\n")
+			continue
+		}
+
+		substitutes, err := asset.GetSubstitutes(candidate.Symbol)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+			continue
+		}
+		if len(substitutes) == 0 {
+			fmt.Printf("\tNo substitute configured, add \"substitutes\" for %s to the assets file\n", candidate.Symbol)
+			continue
+		}
+
+		substitute := substitutes[0]
+		classChange := "class allocation unchanged"
+		if substitute.Class != held.Class || substitute.SubClass != held.SubClass {
+			classChange = fmt.Sprintf("moves %.2f from %s/%s to %s/%s", candidate.Value, held.Class, held.SubClass, substitute.Class, substitute.SubClass)
+		}
+		fmt.Printf("\tSwap to %s (%s)\n", substitute.Symbol, classChange)
+
+		// The plan only counts the substitute if it is one of the allocation's equivalents
+		for _, allocationAsset := range allocationPlan.Allocations {
+			if allocationAsset.HasSymbol(candidate.Symbol) && !allocationAsset.HasSymbol(substitute.Symbol) {
+				fmt.Printf("\tAdd %s to the equivalents of %s in the plan so it keeps counting\n", substitute.Symbol, allocationAsset.Symbol)
+			}
+		}
+	}
+
+	fmt.Printf("Total loss harvested: %.2f\n", totalLoss)
+}
+
+func printTrades(trades []allocations.Trade) {
+	fmt.Println()
+	fmt.Println("Trades:")
+	if len(trades) == 0 {
+		fmt.Println("\tNone")
+		return
+	}
+
+	var buys, sells money.Money
+	for _, trade := range trades {
+		if trade.IsBuy() {
+			buys += trade.Amount
+		} else {
+			sells -= trade.Amount
+		}
+		fmt.Printf("\t%s %s %.2f\n", trade.Action(), trade.Symbol, trade.Amount.Abs())
+	}
+
+	fmt.Printf("Total buys: %.2f Total sells: %.2f\n", buys, sells)
 }
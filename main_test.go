@@ -0,0 +1,118 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/samkreter/portfoli/pkg/importer"
+	"github.com/samkreter/portfoli/pkg/money"
+	"github.com/samkreter/portfoli/portfolio"
+)
+
+func TestConvertCurrencies(t *testing.T) {
+	dir, err := ioutil.TempDir("", "portfoli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fxFile := filepath.Join(dir, "fx.json")
+	if err := ioutil.WriteFile(fxFile, []byte(`{"asOf": "2026-10-16", "quote": "USD", "rates": {"CAD": 0.75}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the lot and the transaction are in CAD
+	newPortfolio := func(transactionCurrency money.Currency) *portfolio.Portfolio {
+		currPortfolio := portfolio.New([]importer.Position{{
+			Account:  "RRSP",
+			Symbol:   "VTI",
+			Quantity: 1,
+			Value:    100 * money.Unit,
+			Currency: money.USD,
+			Lots:     []importer.Lot{{Account: "RRSP", Symbol: "VTI", Quantity: 1, CostPerShare: 100 * money.Unit, Currency: "CAD"}},
+		}})
+		currPortfolio.AddTransactions(importer.Transaction{Account: "RRSP", Symbol: "VTI", Type: importer.Buy, Amount: -100 * money.Unit, Currency: transactionCurrency})
+		return currPortfolio
+	}
+
+	currPortfolio := newPortfolio("CAD")
+	if err := convertCurrencies(currPortfolio, fxFile, money.USD); err != nil {
+		t.Fatal(err)
+	}
+	if lot := currPortfolio.Positions[0].Lots[0]; lot.CostPerShare != 75*money.Unit || lot.Currency != money.USD {
+		t.Errorf("expected the lot to cost 75 USD, got %+v", lot)
+	}
+	if transaction := currPortfolio.Transactions[0]; transaction.Amount != -75*money.Unit || transaction.Currency != money.USD {
+		t.Errorf("expected the transaction to be -75 USD, got %+v", transaction)
+	}
+
+	// There's no GBP rate, nothing is converted
+	currPortfolio = newPortfolio("GBP")
+	err = convertCurrencies(currPortfolio, fxFile, money.USD)
+	if err == nil || !strings.Contains(err.Error(), "GBP") {
+		t.Errorf("expected a missing GBP rate error, got %v", err)
+	}
+	if lot := currPortfolio.Positions[0].Lots[0]; lot.Currency != "CAD" {
+		t.Errorf("expected the lot to stay in CAD, got %+v", lot)
+	}
+
+	// Without a rates file the foreign currencies are listed
+	err = convertCurrencies(newPortfolio("CAD"), filepath.Join(dir, "missing.json"), money.USD)
+	if err == nil || !strings.Contains(err.Error(), "CAD") {
+		t.Errorf("expected an error asking for CAD rates, got %v", err)
+	}
+}
+
+func TestUnclassifiedHoldings(t *testing.T) {
+	currPortfolio := portfolio.New([]importer.Position{
+		{Account: "Brokerage", Symbol: "VTI", Value: 100 * money.Unit},
+		{Account: "Brokerage", Symbol: "MYSTERY", Value: 50 * money.Unit},
+		{Account: "IRA", Symbol: "MYSTERY", Value: 25 * money.Unit},
+		{Account: "Brokerage", Symbol: importer.CashSymbol, Value: 10 * money.Unit},
+	})
+
+	unclassified := unclassifiedHoldings(currPortfolio)
+	if len(unclassified) != 1 || unclassified[0].Symbol != "MYSTERY" || unclassified[0].Value != 75*money.Unit {
+		t.Errorf("expected only MYSTERY worth 75 to be unclassified, got %+v", unclassified)
+	}
+}
+
+func TestImportPortfolioSumsFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "portfoli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	header := "Account Name/Number,Symbol,Description,Quantity,Last Price,Current Value,Cost Basis Total,Type\n"
+	exports := map[string]string{
+		"Portfolio_Positions_1.csv": header +
+			"BROKERAGE X123,VTI,VANGUARD TOTAL STOCK MKT ETF,10,$150.00,$1500.00,$1300.00,Cash\n" +
+			"IRA 555,VTI,VANGUARD TOTAL STOCK MKT ETF,5,$150.00,$750.00,$700.00,Cash\n",
+		"Portfolio_Positions_2.csv": header +
+			"BROKERAGE X123,VTI,VANGUARD TOTAL STOCK MKT ETF,2,$150.00,$300.00,$280.00,Cash\n",
+	}
+	for name, contents := range exports {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	currPortfolio, imports, err := importPortfolio([]string{filepath.Join(dir, "Portfolio_Positions_*.csv")}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imports) != 2 {
+		t.Fatalf("expected both files to be imported, got %d", len(imports))
+	}
+
+	if values := currPortfolio.Values(); values["VTI"] != 2550*money.Unit {
+		t.Errorf("expected VTI worth 2550, got %s", values["VTI"])
+	}
+	if brokerage := currPortfolio.AccountValues()["BROKERAGE X123"]["VTI"]; brokerage != 1800*money.Unit {
+		t.Errorf("expected the brokerage VTI of both files to be summed to 1800, got %s", brokerage)
+	}
+}
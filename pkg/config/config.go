@@ -0,0 +1,72 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+)
+
+const (
+	// HomeEnvVar overrides the directory portfoli reads its configuration from
+	HomeEnvVar = "PORTFOLI_HOME"
+
+	appDirName       = "portfoli"
+	plansDirName     = "plans"
+	accountsFileName = "accounts.json"
+	assetsFileName   = "assets.json"
+	fxFileName       = "fx.json"
+)
+
+// Dir returns the portfoli configuration directory. It defaults to
+// <user config dir>/portfoli and can be overridden with $PORTFOLI_HOME.
+func Dir() (string, error) {
+	if dir := os.Getenv(HomeEnvVar); dir != "" {
+		return dir, nil
+	}
+
+	userConfigDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(userConfigDir, appDirName), nil
+}
+
+// PlansDir returns the directory user allocation plan files are loaded from
+func PlansDir() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, plansDirName), nil
+}
+
+// AccountsFile returns the file account metadata is loaded from
+func AccountsFile() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, accountsFileName), nil
+}
+
+// AssetsFile returns the file user defined assets are loaded from
+func AssetsFile() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, assetsFileName), nil
+}
+
+// FXFile returns the file exchange rates are loaded from
+func FXFile() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, fxFileName), nil
+}
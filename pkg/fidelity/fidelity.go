@@ -1,6 +1,7 @@
 package fidelity
 
 import (
+	"bytes"
 	"encoding/csv"
 	"fmt"
 	"io"
@@ -10,50 +11,159 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/samkreter/portfoli/pkg/importer"
+	"github.com/samkreter/portfoli/pkg/money"
 )
 
 const (
 	defaultFileMatcher = "Portfolio_Position"
+
+	importerName = "fidelity"
+
+	byteOrderMark = "\ufeff"
 )
 
-// GetCurrentPositions parses the passed in Fidelity Portfoli file. If no filepath is used.
-// A default filepath of <user's homedir>/Downloads/Protfolio_Positions*. If mutliple files exists, the last modified will be used.
-func GetCurrentPositions(filename string) ([]*FidelityRow, error) {
-	var err error
-	// get default filename
-	if filename == "" {
-		filename, err = getDefaultFidelityPortfoliPath()
+func init() {
+	importer.Register(Importer{})
+}
+
+// Importer imports Fidelity "Portfolio Positions" csv exports
+type Importer struct{}
+
+// Name returns the name of the importer
+func (Importer) Name() string {
+	return importerName
+}
+
+// Detect reports whether head is the start of a Fidelity positions export
+func (Importer) Detect(head []byte) bool {
+	firstLine := head
+	if idx := bytes.IndexByte(head, '\n'); idx >= 0 {
+		firstLine = head[:idx]
+	}
+
+	// The lots export shares the account column, see LotsImporter
+	if bytes.Contains(firstLine, []byte(lotsHeaderMarker)) {
+		return false
+	}
+
+	hasAccount := false
+	for _, alias := range []string{"Account Name/Number", "Account Number"} {
+		hasAccount = hasAccount || bytes.Contains(firstLine, []byte(alias))
+	}
+
+	return hasAccount && bytes.Contains(firstLine, []byte("Symbol")) && bytes.Contains(firstLine, []byte("Current Value"))
+}
+
+// Parse reads the positions from a Fidelity positions export
+func (Importer) Parse(r io.Reader) (importer.Result, error) {
+	rows, err := readCSV(r)
+	if err != nil {
+		return importer.Result{}, err
+	}
+
+	if len(rows) == 0 {
+		return importer.Result{}, nil
+	}
+
+	// The first row is the header
+	columns, err := parseHeader(rows[0])
+	if err != nil {
+		return importer.Result{}, err
+	}
+
+	result := importer.Result{}
+	for idx := 1; idx < len(rows); idx++ {
+		if isFooterRow(rows[idx]) {
+			result.Skip(idx+1, rows[idx][0], "footer")
+			continue
+		}
+
+		fRow, err := parseRow(rows[idx], columns)
 		if err != nil {
-			return nil, err
+			result.Reject(idx+1, err)
+			continue
 		}
-		fmt.Printf("Using default Fidelity portfoli file: %q\n", filename)
+
+		result.Positions = append(result.Positions, fRow.ToPosition())
+	}
+
+	return result, nil
+}
+
+// ToPosition converts the Fidelity row to a broker neutral position
+func (row *FidelityRow) ToPosition() importer.Position {
+	position := importer.Position{
+		Account:       row.AccountName,
+		Symbol:        row.Symbol,
+		Description:   row.Description,
+		Quantity:      row.Quantity,
+		LastPrice:     row.LastPrice.Value,
+		Value:         row.Current.Value,
+		CostBasis:     row.CostBasisTotal.Value,
+		TotalGainLoss: row.TotalGainLossDollar.Value,
+		Type:          row.Type,
+		Currency:      row.currency(),
+	}
+
+	switch {
+	case row.Symbol == pendingActivitySymbol:
+		position.Symbol = importer.PendingSymbol
+		position.Description = pendingActivitySymbol
+		position.Cash = true
+	case strings.HasSuffix(row.Symbol, coreMarker):
+		// The core position is the money market fund the account's cash is swept into
+		position.Symbol = strings.TrimSuffix(row.Symbol, coreMarker)
+		position.Cash = true
 	}
 
-	f, err := os.Open(filename)
+	// Cash is its own price
+	if position.Cash && position.Quantity == 0 {
+		position.Quantity = position.Value.Float64()
+		position.LastPrice = money.Unit
+	}
+
+	return position
+}
+
+// currency returns the first currency printed on the row, the position's
+// value is left blank for some holdings so the price is checked too
+func (row *FidelityRow) currency() money.Currency {
+	for _, amount := range []*Currency{row.Current, row.LastPrice, row.CostBasisTotal} {
+		if amount != nil && amount.Type != "" {
+			return amount.Type
+		}
+	}
+	return ""
+}
+
+// DefaultPositionsFile returns the default Fidelity positions export, the last modified
+// <user's homedir>/Downloads/Portfolio_Position* file.
+func DefaultPositionsFile() (string, error) {
+	usr, err := user.Current()
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	defer f.Close()
 
-	rows, err := readCSV(f)
+	filename, err := getLastModifiedFile(usr.HomeDir+"/Downloads", defaultFileMatcher)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	currentPositions := []*FidelityRow{}
-	for _, row := range rows {
-		fRow := parseRow(row)
-		if fRow != nil {
-			currentPositions = append(currentPositions, fRow)
-		}
+	if filename == "" {
+		return "", fmt.Errorf("no %s* file found in %s/Downloads", defaultFileMatcher, usr.HomeDir)
 	}
 
-	return currentPositions, nil
+	return filename, nil
 }
 
-func readCSV(f *os.File) ([][]string, error) {
+// readCSV reads every row of an export, the rows can have any number of
+// columns since the exports have text like disclaimers at the end
+func readCSV(r io.Reader) ([][]string, error) {
 	rows := [][]string{}
-	csvReader := csv.NewReader(f)
+	csvReader := csv.NewReader(r)
+	csvReader.FieldsPerRecord = -1
 	csvReader.LazyQuotes = true
 
 	for {
@@ -62,11 +172,6 @@ func readCSV(f *os.File) ([][]string, error) {
 			if err == io.EOF {
 				return rows, nil
 			}
-			// ignore invalid filed count since the fidelity csv's have junk at the end
-			if err, ok := err.(*csv.ParseError); ok && err.Err == csv.ErrFieldCount {
-				continue
-			}
-
 			return nil, err
 		}
 
@@ -74,13 +179,10 @@ func readCSV(f *os.File) ([][]string, error) {
 	}
 }
 
-func getDefaultFidelityPortfoliPath() (string, error) {
-	usr, err := user.Current()
-	if err != nil {
-		return "", err
-	}
-
-	return getLastModifiedFile(usr.HomeDir+"/Downloads", defaultFileMatcher)
+// isFooterRow reports whether the row is text at the end of an export, e.g.
+// "Date downloaded ...", rather than a holding missing some columns
+func isFooterRow(row []string) bool {
+	return len(row) == 1 || importer.IsBlankRow(row[1:])
 }
 
 func getLastModifiedFile(dirPath, substrMatcher string) (string, error) {
@@ -101,7 +203,7 @@ func getLastModifiedFile(dirPath, substrMatcher string) (string, error) {
 		}
 
 		// contains matching substring
-		if !strings.ContainsAny(info.Name(), substrMatcher) {
+		if !strings.Contains(info.Name(), substrMatcher) {
 			return nil
 		}
 
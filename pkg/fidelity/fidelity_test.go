@@ -0,0 +1,73 @@
+package fidelity
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/samkreter/portfoli/pkg/money"
+)
+
+func TestParseReorderedColumns(t *testing.T) {
+	export := byteOrderMark + "Account Number,Account Name,Symbol,Description,Quantity,Last Price,Current Value,Percent Of Account,Cost Basis Total,Average Cost Basis,Type\n" +
+		"X123,BROKERAGE,VTI,VANGUARD TOTAL STOCK MKT ETF,100,$150.00,$15000.00,75.00%,$13000.00,$130.00,Cash,\n" +
+		"\"Date downloaded Oct-18-2026\"\n"
+
+	if !(Importer{}).Detect([]byte(export)) {
+		t.Fatal("expected the export to be detected")
+	}
+
+	result, err := Importer{}.Parse(strings.NewReader(export))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Positions) != 1 {
+		t.Fatalf("expected 1 position, got %+v", result)
+	}
+
+	vti := result.Positions[0]
+	if vti.Account != "BROKERAGE X123" || vti.Symbol != "VTI" || vti.Quantity != 100 ||
+		vti.LastPrice != money.MustParse("150") || vti.Value != money.MustParse("15000") || vti.CostBasis != money.MustParse("13000") {
+		t.Errorf("unexpected VTI position: %+v", vti)
+	}
+}
+
+func TestParseMissingColumns(t *testing.T) {
+	export := "Account Name/Number,Symbol,Description,Last Price\nBROKERAGE,VTI,VANGUARD TOTAL STOCK MKT ETF,$150.00\n"
+
+	_, err := Importer{}.Parse(strings.NewReader(export))
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	for _, column := range []string{"Quantity", "Current Value"} {
+		if !strings.Contains(err.Error(), column) {
+			t.Errorf("expected %q to list the missing %s column", err, column)
+		}
+	}
+}
+
+func TestParseRowLegacyLayout(t *testing.T) {
+	header := []string{"Account Name/Number", "Symbol", "Description", "Quantity", "Last Price", "Last Price Change", "Current Value",
+		"Today's Gain/Loss Dollar", "Today's Gain/Loss Percent", "Total Gain/Loss Dollar", "Total Gain/Loss Percent",
+		"Cost Basis Per Share", "Cost Basis Total", "Type"}
+	row := []string{"BROKERAGE X123", "VTI", "VANGUARD TOTAL STOCK MKT ETF", "100", "$150.00", "+$1.50", "$15,000.00",
+		"+$150.00", "+1.01%", "-$2,000.00", "-11.76%", "$170.00", "$17,000.00", "Cash"}
+
+	columns, err := parseHeader(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fRow, err := parseRow(row, columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fRow.AccountName != "BROKERAGE X123" || fRow.CostBasisPerShare.Value != money.MustParse("170") ||
+		fRow.TotalGainLossDollar.Value != money.MustParse("-2000") {
+		t.Errorf("unexpected row: %+v", fRow)
+	}
+	if fRow.TodaysGainLossPercent != .0101 || fRow.TotalGainLossPercent != -.1176 {
+		t.Errorf("expected the percents as fractions, got %v and %v", fRow.TodaysGainLossPercent, fRow.TotalGainLossPercent)
+	}
+}
@@ -0,0 +1,151 @@
+package fidelity
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/samkreter/portfoli/pkg/importer"
+	"github.com/samkreter/portfoli/pkg/money"
+)
+
+const (
+	historyImporterName = "fidelity-history"
+	historyHeaderMarker = "Run Date"
+	historyDateLayout   = "01/02/2006"
+)
+
+// historyActions maps the start of Fidelity's action descriptions to a transaction type
+var historyActions = []struct {
+	prefix          string
+	transactionType importer.TransactionType
+}{
+	{"YOU BOUGHT", importer.Buy},
+	{"YOU SOLD", importer.Sell},
+	{"REINVESTMENT", importer.Reinvestment},
+	{"DIVIDEND RECEIVED", importer.Dividend},
+}
+
+func init() {
+	importer.Register(HistoryImporter{})
+}
+
+// HistoryImporter imports the "Activity & Orders" history csv export
+type HistoryImporter struct{}
+
+// Name returns the name of the importer
+func (HistoryImporter) Name() string {
+	return historyImporterName
+}
+
+// Detect reports whether head is a Fidelity history export, the header comes after some blank lines
+func (HistoryImporter) Detect(head []byte) bool {
+	for _, line := range bytes.Split(head, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		return bytes.HasPrefix(bytes.TrimSpace(line), []byte(historyHeaderMarker))
+	}
+
+	return false
+}
+
+// Parse reads the transactions from a Fidelity history export
+func (HistoryImporter) Parse(r io.Reader) (importer.Result, error) {
+	rows, err := readCSV(r)
+	if err != nil {
+		return importer.Result{}, err
+	}
+
+	result := importer.Result{}
+	var columns map[string]int
+	for idx, row := range rows {
+		if importer.IsBlankRow(row) {
+			continue
+		}
+
+		if columns == nil {
+			if strings.TrimSpace(row[0]) != historyHeaderMarker {
+				result.Skip(idx+1, row[0], "before the header")
+				continue
+			}
+
+			columns = map[string]int{}
+			for colIdx, name := range row {
+				columns[strings.TrimSpace(name)] = colIdx
+			}
+			continue
+		}
+
+		if isFooterRow(row) {
+			result.Skip(idx+1, row[0], "footer")
+			continue
+		}
+
+		transaction, err := parseTransaction(row, columns)
+		if err != nil {
+			result.Reject(idx+1, err)
+			continue
+		}
+
+		result.Transactions = append(result.Transactions, transaction)
+	}
+
+	return result, nil
+}
+
+func parseTransaction(row []string, columns map[string]int) (importer.Transaction, error) {
+	get := func(name string) string {
+		idx, ok := columns[name]
+		if !ok || idx >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[idx])
+	}
+
+	date, err := time.Parse(historyDateLayout, get(historyHeaderMarker))
+	if err != nil {
+		return importer.Transaction{}, &importer.FieldError{Column: historyHeaderMarker, Raw: get(historyHeaderMarker), Err: fmt.Errorf("invalid date, expected %s", historyDateLayout)}
+	}
+
+	transaction := importer.Transaction{
+		Account: get("Account"),
+		Date:    date,
+		Type:    importer.Other,
+		Symbol:  get("Symbol"),
+	}
+
+	action := strings.ToUpper(get("Action"))
+	for _, historyAction := range historyActions {
+		if strings.HasPrefix(action, historyAction.prefix) {
+			transaction.Type = historyAction.transactionType
+			break
+		}
+	}
+
+	quantity, err := importer.ParseAmount(get("Quantity"))
+	if err != nil {
+		return importer.Transaction{}, &importer.FieldError{Column: "Quantity", Raw: get("Quantity"), Err: err}
+	}
+	transaction.Quantity = quantity
+
+	amounts := []struct {
+		column string
+		value  *money.Money
+	}{
+		{"Price ($)", &transaction.Price},
+		{"Amount ($)", &transaction.Amount},
+	}
+	for _, amount := range amounts {
+		val, err := importer.ParseMoney(get(amount.column))
+		if err != nil {
+			return importer.Transaction{}, &importer.FieldError{Column: amount.column, Raw: get(amount.column), Err: err}
+		}
+		*amount.value = val
+	}
+
+	return transaction, nil
+}
@@ -0,0 +1,151 @@
+package fidelity
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/samkreter/portfoli/pkg/importer"
+	"github.com/samkreter/portfoli/pkg/money"
+)
+
+const (
+	lotsImporterName = "fidelity-lots"
+	lotsHeaderMarker = "Date Acquired"
+)
+
+// lotDateLayouts are the date formats Fidelity has used for the acquired date
+var lotDateLayouts = []string{"01/02/2006", "Jan-02-2006", "2006-01-02"}
+
+// lotColumnAliases lists the header names of each lot column, the first entry is the canonical name
+var lotColumnAliases = map[string][]string{
+	"Account":              {"Account Name/Number", "Account Number", "Account"},
+	"Symbol":               {"Symbol"},
+	"Date Acquired":        {"Date Acquired"},
+	"Quantity":             {"Quantity"},
+	"Cost Basis Per Share": {"Cost Basis Per Share", "Cost Basis/Share"},
+	"Cost Basis":           {"Cost Basis", "Cost Basis Total"},
+}
+
+func init() {
+	importer.Register(LotsImporter{})
+}
+
+// LotsImporter imports the per lot cost basis export of Fidelity's unrealized gain/loss page
+type LotsImporter struct{}
+
+// Name returns the name of the importer
+func (LotsImporter) Name() string {
+	return lotsImporterName
+}
+
+// Detect reports whether head is the start of a Fidelity lots export
+func (LotsImporter) Detect(head []byte) bool {
+	firstLine := head
+	if idx := bytes.IndexByte(head, '\n'); idx >= 0 {
+		firstLine = head[:idx]
+	}
+
+	return bytes.Contains(firstLine, []byte(lotsHeaderMarker)) && bytes.Contains(firstLine, []byte("Symbol"))
+}
+
+// Parse reads the tax lots from a Fidelity lots export
+func (LotsImporter) Parse(r io.Reader) (importer.Result, error) {
+	rows, err := readCSV(r)
+	if err != nil {
+		return importer.Result{}, err
+	}
+	if len(rows) == 0 {
+		return importer.Result{}, nil
+	}
+
+	columns := map[string]int{}
+	for idx, name := range rows[0] {
+		for canonical, aliases := range lotColumnAliases {
+			for _, alias := range aliases {
+				if _, ok := columns[canonical]; !ok && strings.TrimSpace(name) == alias {
+					columns[canonical] = idx
+				}
+			}
+		}
+	}
+	for _, required := range []string{"Symbol", "Date Acquired", "Quantity"} {
+		if _, ok := columns[required]; !ok {
+			return importer.Result{}, fmt.Errorf("lots export is missing the %q column", required)
+		}
+	}
+
+	result := importer.Result{}
+	for idx := 1; idx < len(rows); idx++ {
+		if isFooterRow(rows[idx]) {
+			result.Skip(idx+1, rows[idx][0], "footer")
+			continue
+		}
+
+		lot, err := parseLot(rows[idx], columns)
+		if err != nil {
+			result.Reject(idx+1, err)
+			continue
+		}
+
+		result.Lots = append(result.Lots, lot)
+	}
+
+	return result, nil
+}
+
+func parseLot(row []string, columns map[string]int) (importer.Lot, error) {
+	get := func(name string) string {
+		idx, ok := columns[name]
+		if !ok || idx >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[idx])
+	}
+
+	acquired, err := parseLotDate(get("Date Acquired"))
+	if err != nil {
+		return importer.Lot{}, &importer.FieldError{Column: "Date Acquired", Raw: get("Date Acquired"), Err: err}
+	}
+
+	quantity, err := importer.ParseAmount(get("Quantity"))
+	if err != nil {
+		return importer.Lot{}, &importer.FieldError{Column: "Quantity", Raw: get("Quantity"), Err: err}
+	}
+
+	costPerShare, currency, err := importer.ParseCurrencyMoney(get("Cost Basis Per Share"))
+	if err != nil {
+		return importer.Lot{}, &importer.FieldError{Column: "Cost Basis Per Share", Raw: get("Cost Basis Per Share"), Err: err}
+	}
+
+	// Older exports only have the total cost of the lot
+	if costPerShare == 0 && quantity != 0 {
+		var costBasis money.Money
+		costBasis, currency, err = importer.ParseCurrencyMoney(get("Cost Basis"))
+		if err != nil {
+			return importer.Lot{}, &importer.FieldError{Column: "Cost Basis", Raw: get("Cost Basis"), Err: err}
+		}
+		costPerShare = costBasis.Div(quantity)
+	}
+
+	return importer.Lot{
+		Account:      get("Account"),
+		Symbol:       get("Symbol"),
+		Acquired:     acquired,
+		Quantity:     quantity,
+		CostPerShare: costPerShare,
+		Currency:     currency,
+	}, nil
+}
+
+func parseLotDate(raw string) (time.Time, error) {
+	for _, layout := range lotDateLayouts {
+		if acquired, err := time.Parse(layout, raw); err == nil {
+			return acquired, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid date, expected one of %v", lotDateLayouts)
+}
@@ -2,15 +2,78 @@ package fidelity
 
 import (
 	"fmt"
-	"log"
 	"strconv"
 	"strings"
+
+	"github.com/samkreter/portfoli/pkg/importer"
+	"github.com/samkreter/portfoli/pkg/money"
 )
 
+// exportVersion is a layout of Fidelity's positions export, columns maps the
+// canonical name of each column to its header in the version. Columns that
+// aren't listed, like "Percent Of Account", are ignored.
+type exportVersion struct {
+	name    string
+	columns map[string]string
+}
+
+// exportVersions are the positions export layouts, the canonical names are the
+// headers of the oldest one
+var exportVersions = []exportVersion{
+	{
+		name: "2022 and earlier",
+		columns: map[string]string{
+			"Account Name/Number":       "Account Name/Number",
+			"Symbol":                    "Symbol",
+			"Description":               "Description",
+			"Quantity":                  "Quantity",
+			"Last Price":                "Last Price",
+			"Last Price Change":         "Last Price Change",
+			"Current Value":             "Current Value",
+			"Today's Gain/Loss Dollar":  "Today's Gain/Loss Dollar",
+			"Today's Gain/Loss Percent": "Today's Gain/Loss Percent",
+			"Total Gain/Loss Dollar":    "Total Gain/Loss Dollar",
+			"Total Gain/Loss Percent":   "Total Gain/Loss Percent",
+			"Cost Basis Per Share":      "Cost Basis Per Share",
+			"Cost Basis Total":          "Cost Basis Total",
+			"Type":                      "Type",
+		},
+	},
+	{
+		// The account name and number got their own columns, "Percent Of
+		// Account" was added and only the average cost of the shares is left
+		name: "2023 and later",
+		columns: map[string]string{
+			"Account Number":            "Account Number",
+			"Account Name":              "Account Name",
+			"Symbol":                    "Symbol",
+			"Description":               "Description",
+			"Quantity":                  "Quantity",
+			"Last Price":                "Last Price",
+			"Last Price Change":         "Last Price Change",
+			"Current Value":             "Current Value",
+			"Today's Gain/Loss Dollar":  "Today's Gain/Loss Dollar",
+			"Today's Gain/Loss Percent": "Today's Gain/Loss Percent",
+			"Total Gain/Loss Dollar":    "Total Gain/Loss Dollar",
+			"Total Gain/Loss Percent":   "Total Gain/Loss Percent",
+			"Cost Basis Total":          "Cost Basis Total",
+			"Cost Basis Per Share":      "Average Cost Basis",
+			"Type":                      "Type",
+		},
+	},
+}
+
+// requiredColumns are the columns a holding can't be read without, besides the account
+var requiredColumns = []string{"Symbol", "Quantity", "Last Price", "Current Value"}
+
 const (
-	rowLength = 14
 	naConst   = "n/a"
 	emptyMark = "--"
+
+	// pendingActivitySymbol is the symbol of the row with the account's unsettled activity
+	pendingActivitySymbol = "Pending Activity"
+	// coreMarker follows the symbol of the account's core position
+	coreMarker = "**"
 )
 
 type FidelityRow struct {
@@ -40,8 +103,8 @@ func (p Percent) String() string {
 	return fmt.Sprintf("%f", p)
 }
 
-// Convert the CSV string as internal date
-func (p Percent) UnmarshalCSV(csv string) (err error) {
+// UnmarshalCSV parses percents like "-12.34%" into a fraction, -0.1234
+func (p *Percent) UnmarshalCSV(csv string) (err error) {
 	if len(csv) < 2 {
 		return nil
 	}
@@ -68,25 +131,30 @@ func (p Percent) UnmarshalCSV(csv string) (err error) {
 		return err
 	}
 
-	p = Percent(val * .001)
+	*p = Percent(val / 100)
 
 	return nil
 }
 
+// Currency is an amount along with the ISO currency it's printed in, empty
+// when the export didn't print a symbol or code
 type Currency struct {
-	Type  string
-	Value float64
+	Type  money.Currency
+	Value money.Money
 }
 
 func (c *Currency) MarshalCSV() (string, error) {
-	return fmt.Sprintf("%s%f", c.Type, c.Value), nil
+	return c.String(), nil
 }
 
 func (c *Currency) String() string {
-	return fmt.Sprintf("%s%f", c.Type, c.Value) // Redundant, just for example
+	if c.Type == "" {
+		return c.Value.String()
+	}
+	return fmt.Sprintf("%s %s", c.Value, c.Type)
 }
 
-// TODO: Make work with all currency, not just USD
+// UnmarshalCSV parses amounts like "$1,234.56", "-C$12.00" or "1,234.56 CAD"
 func (c *Currency) UnmarshalCSV(csv string) (err error) {
 	if len(csv) < 2 {
 		return nil
@@ -102,113 +170,145 @@ func (c *Currency) UnmarshalCSV(csv string) (err error) {
 		return nil
 	}
 
-	//c.Type = string(csv[0])
-	c.Type = "$"
-
-	valStr := strings.Replace(csv[1:], ",", "", -1)
-	valStr = strings.Replace(csv[1:], "$", "", -1)
-
-	val, err := strconv.ParseFloat(valStr, 64)
+	// Handles the sign before the $ of losses, e.g. "-$1,234.56"
+	val, currency, err := importer.ParseCurrencyMoney(csv)
 	if err != nil {
 		return err
 	}
 
+	c.Type = currency
 	c.Value = val
 
 	return nil
 }
 
-func parseRow(row []string) *FidelityRow {
-	if len(row) < rowLength {
-		log.Printf("ERROR: row length is: '%d', expected: '%d'", len(row), rowLength)
-		return nil
+// parseHeader maps the canonical column names to their index in the header
+// row. Headers are matched against the export version they fit best, then any
+// other version's, so a layout mixing them still parses. It fails with every
+// required column it couldn't find.
+func parseHeader(row []string) (map[string]int, error) {
+	indexes := map[string]int{}
+	for idx, name := range row {
+		name = strings.TrimSpace(strings.TrimPrefix(name, byteOrderMark))
+		if _, ok := indexes[name]; !ok {
+			indexes[name] = idx
+		}
 	}
 
-	var quantity float64
-	var err error
-	if row[3] != naConst {
-		quantity, err = strconv.ParseFloat(row[3], 64)
-		if err != nil {
-			log.Println(row[3], "ERROR-quantity: ", err)
-			return nil
+	match := func(version exportVersion) map[string]int {
+		columns := map[string]int{}
+		for canonical, header := range version.columns {
+			if idx, ok := indexes[header]; ok {
+				columns[canonical] = idx
+			}
 		}
+		return columns
 	}
 
-	lastPrice := &Currency{}
-	if err := lastPrice.UnmarshalCSV(row[4]); err != nil {
-		log.Println("ERROR-lastPrice: ", err)
-		return nil
+	best := exportVersions[0]
+	columns := match(best)
+	for _, version := range exportVersions[1:] {
+		if versionColumns := match(version); len(versionColumns) > len(columns) {
+			best, columns = version, versionColumns
+		}
 	}
-
-	lastPriceChange := &Currency{}
-	err = lastPriceChange.UnmarshalCSV(row[5])
-	if err != nil {
-		log.Println("ERROR-lastPriceChange: ", err)
-		return nil
+	for _, version := range exportVersions {
+		for canonical, idx := range match(version) {
+			if _, ok := columns[canonical]; !ok {
+				columns[canonical] = idx
+			}
+		}
 	}
 
-	current := &Currency{}
-	err = current.UnmarshalCSV(row[6])
-	if err != nil {
-		log.Println("ERROR-current: ", err)
-		return nil
+	missing := []string{}
+	if _, ok := columns["Account Name/Number"]; !ok {
+		if _, ok := columns["Account Number"]; !ok {
+			missing = append(missing, "Account Name/Number or Account Number")
+		}
+	}
+	for _, required := range requiredColumns {
+		if _, ok := columns[required]; !ok {
+			missing = append(missing, best.columns[required])
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("positions export, closest to the %s layout, is missing the %q columns, the header is %q", best.name, missing, row)
 	}
 
-	todaysGainLossDollar := &Currency{}
-	if err := todaysGainLossDollar.UnmarshalCSV(row[7]); err != nil {
-		log.Println("ERROR-todaysGainLossDollar: ", err)
-		return nil
+	return columns, nil
+}
+
+func parseRow(row []string, columns map[string]int) (*FidelityRow, error) {
+	missing := []string{}
+	for _, required := range requiredColumns {
+		if columns[required] >= len(row) {
+			missing = append(missing, required)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("row has %d columns, missing %q", len(row), missing)
 	}
 
-	todaysGainLossPercent := Percent(0.0)
-	err = todaysGainLossPercent.UnmarshalCSV(row[8])
-	if err != nil {
-		log.Println(row[8], "   ERROR-todaysGainLossPercent: ", err)
-		return nil
+	get := func(name string) string {
+		idx, ok := columns[name]
+		if !ok || idx >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[idx])
 	}
 
-	totalGainLossDollar := &Currency{}
-	err = totalGainLossDollar.UnmarshalCSV(row[9])
-	if err != nil {
-		log.Println("ERROR-totalGainLossDollar: ", err)
-		return nil
+	fRow := &FidelityRow{
+		AccountName: get("Account Name/Number"),
+		Symbol:      get("Symbol"),
+		Description: get("Description"),
+		Type:        get("Type"),
 	}
 
-	totalGainLossPercent := Percent(0.0)
-	err = totalGainLossPercent.UnmarshalCSV(row[10])
-	if err != nil {
-		log.Println("ERROR-totalGainLossPercent: ", err)
-		return nil
+	// Newer exports split the account, join it back like older exports had it
+	if fRow.AccountName == "" {
+		fRow.AccountName = strings.TrimSpace(get("Account Name") + " " + get("Account Number"))
 	}
 
-	costBasisPerShare := &Currency{}
-	err = costBasisPerShare.UnmarshalCSV(row[11])
-	if err != nil {
-		log.Println("ERROR-costBasisPerShare: ", err)
-		return nil
+	// Pending activity and some core positions have no quantity
+	if raw := get("Quantity"); raw != naConst && raw != "" {
+		quantity, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, &importer.FieldError{Column: "Quantity", Raw: raw, Err: err}
+		}
+		fRow.Quantity = quantity
 	}
 
-	costBasisTotal := &Currency{}
-	err = costBasisTotal.UnmarshalCSV(row[12])
-	if err != nil {
-		log.Println("ERROR-costBasisTotal: ", err)
-		return nil
+	amounts := []struct {
+		column string
+		value  **Currency
+	}{
+		{"Last Price", &fRow.LastPrice},
+		{"Last Price Change", &fRow.LastPriceChange},
+		{"Current Value", &fRow.Current},
+		{"Today's Gain/Loss Dollar", &fRow.TodaysGainLossDollar},
+		{"Total Gain/Loss Dollar", &fRow.TotalGainLossDollar},
+		{"Cost Basis Per Share", &fRow.CostBasisPerShare},
+		{"Cost Basis Total", &fRow.CostBasisTotal},
+	}
+	for _, amount := range amounts {
+		*amount.value = &Currency{}
+		if err := (*amount.value).UnmarshalCSV(get(amount.column)); err != nil {
+			return nil, &importer.FieldError{Column: amount.column, Raw: get(amount.column), Err: err}
+		}
 	}
 
-	return &FidelityRow{
-		AccountName:           row[0],
-		Symbol:                row[1],
-		Description:           row[2],
-		Quantity:              quantity,
-		LastPrice:             lastPrice,
-		LastPriceChange:       lastPriceChange,
-		Current:               current,
-		TodaysGainLossDollar:  todaysGainLossDollar,
-		TodaysGainLossPercent: todaysGainLossPercent,
-		TotalGainLossDollar:   totalGainLossDollar,
-		TotalGainLossPercent:  totalGainLossPercent,
-		CostBasisPerShare:     costBasisPerShare,
-		CostBasisTotal:        costBasisTotal,
-		Type:                  row[13],
+	percents := []struct {
+		column string
+		value  *Percent
+	}{
+		{"Today's Gain/Loss Percent", &fRow.TodaysGainLossPercent},
+		{"Total Gain/Loss Percent", &fRow.TotalGainLossPercent},
 	}
+	for _, percent := range percents {
+		if err := percent.value.UnmarshalCSV(get(percent.column)); err != nil {
+			return nil, &importer.FieldError{Column: percent.column, Raw: get(percent.column), Err: err}
+		}
+	}
+
+	return fRow, nil
 }
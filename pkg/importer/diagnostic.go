@@ -0,0 +1,116 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Severity is what happened to the part of an export a diagnostic is about
+type Severity string
+
+const (
+	// Skipped is for rows that aren't data, e.g. the disclaimers at the end of an export
+	Skipped = Severity("skipped")
+	// Warning is for problems that didn't drop anything
+	Warning = Severity("warning")
+	// Rejected is for rows that look like data but couldn't be parsed and were dropped
+	Rejected = Severity("rejected")
+)
+
+// Diagnostic is a problem found while parsing an export
+type Diagnostic struct {
+	File string
+	// Line is the record of a CSV export, not counting blank lines, or the line
+	// an OFX element starts on, counting from 1. It's 0 when the diagnostic isn't
+	// about a single row or element.
+	Line     int
+	Column   string
+	Raw      string
+	Severity Severity
+	Message  string
+}
+
+// String formats the diagnostic like "file:12: rejected: Quantity "1.2.3": invalid syntax"
+func (d Diagnostic) String() string {
+	var b strings.Builder
+	if d.File != "" {
+		b.WriteString(d.File + ":")
+	}
+	if d.Line > 0 {
+		fmt.Fprintf(&b, "%d:", d.Line)
+	}
+	if b.Len() > 0 {
+		b.WriteString(" ")
+	}
+
+	fmt.Fprintf(&b, "%s: ", d.Severity)
+	if d.Column != "" {
+		fmt.Fprintf(&b, "%s %q: ", d.Column, d.Raw)
+	} else if d.Raw != "" {
+		fmt.Fprintf(&b, "%q: ", d.Raw)
+	}
+	b.WriteString(d.Message)
+
+	return b.String()
+}
+
+// FieldError is a cell of an export that couldn't be parsed
+type FieldError struct {
+	Column string
+	Raw    string
+	Err    error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s %q: %v", e.Column, e.Raw, e.Err)
+}
+
+// Skip records a row that was skipped on purpose, raw is the start of the row
+func (r *Result) Skip(line int, raw, reason string) {
+	r.Diagnostics = append(r.Diagnostics, Diagnostic{
+		Line:     line,
+		Raw:      raw,
+		Severity: Skipped,
+		Message:  reason,
+	})
+}
+
+// Warn records a problem that didn't drop anything
+func (r *Result) Warn(line int, format string, args ...interface{}) {
+	r.Diagnostics = append(r.Diagnostics, Diagnostic{
+		Line:     line,
+		Severity: Warning,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// Reject records a row that was dropped because of err, the column and raw
+// value are filled in when err is a *FieldError
+func (r *Result) Reject(line int, err error) {
+	diagnostic := Diagnostic{
+		Line:     line,
+		Severity: Rejected,
+		Message:  err.Error(),
+	}
+
+	var fieldErr *FieldError
+	if errors.As(err, &fieldErr) {
+		diagnostic.Column = fieldErr.Column
+		diagnostic.Raw = fieldErr.Raw
+		diagnostic.Message = fieldErr.Err.Error()
+	}
+
+	r.Diagnostics = append(r.Diagnostics, diagnostic)
+}
+
+// Count returns the number of diagnostics of severity
+func (r Result) Count(severity Severity) int {
+	count := 0
+	for _, diagnostic := range r.Diagnostics {
+		if diagnostic.Severity == severity {
+			count++
+		}
+	}
+	return count
+}
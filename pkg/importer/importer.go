@@ -0,0 +1,137 @@
+package importer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/samkreter/portfoli/pkg/money"
+)
+
+const (
+	// detectSize is how much of an export is handed to Importer.Detect
+	detectSize = 4096
+)
+
+var (
+	ErrUnknownFormat = errors.New("unknown brokerage export format")
+)
+
+// Importer parses a brokerage export into positions
+type Importer interface {
+	// Name returns the unique name of the importer, e.g. "fidelity"
+	Name() string
+
+	// Detect reports whether the start of an export is in this importer's format
+	Detect(head []byte) bool
+
+	// Parse reads all positions from an export
+	Parse(r io.Reader) (Result, error)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Importer{}
+)
+
+// Register makes an importer available by name and for format detection.
+// It panics if an importer with the same name is already registered.
+func Register(imp Importer) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, ok := registry[imp.Name()]; ok {
+		panic(fmt.Sprintf("importer: Register called twice for %q", imp.Name()))
+	}
+	registry[imp.Name()] = imp
+}
+
+// Get returns the importer registered under name
+func Get(name string) (Importer, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	imp, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown importer: %s", name)
+	}
+
+	return imp, nil
+}
+
+// Names returns the sorted names of all registered importers
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := []string{}
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Detect returns the first registered importer, by name, that recognizes head
+func Detect(head []byte) (Importer, error) {
+	for _, name := range Names() {
+		imp, err := Get(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if imp.Detect(head) {
+			return imp, nil
+		}
+	}
+
+	return nil, ErrUnknownFormat
+}
+
+// ImportFile parses the export at filename with the named importer. An empty
+// name detects the importer from the contents of the file.
+func ImportFile(filename, name string) (Result, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return Result{}, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReaderSize(f, detectSize)
+
+	var imp Importer
+	if name != "" {
+		imp, err = Get(name)
+	} else {
+		// Peek returns what it could read along with io.EOF for short files
+		head, peekErr := r.Peek(detectSize)
+		if peekErr != nil && peekErr != io.EOF && peekErr != bufio.ErrBufferFull {
+			return Result{}, peekErr
+		}
+		imp, err = Detect(head)
+	}
+	if err != nil {
+		return Result{}, fmt.Errorf("%s: %v", filename, err)
+	}
+
+	result, err := imp.Parse(r)
+	if err != nil {
+		return Result{}, fmt.Errorf("%s: %v", filename, err)
+	}
+
+	// US brokerages only print a currency for foreign amounts, if at all
+	result.setDefaultCurrency(money.USD)
+
+	result.File = filename
+	result.Importer = imp.Name()
+	for idx := range result.Diagnostics {
+		result.Diagnostics[idx].File = filename
+	}
+
+	return result, nil
+}
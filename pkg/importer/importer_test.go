@@ -0,0 +1,95 @@
+package importer
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+// fakeImporter claims exports starting with its prefix and returns one
+// position named after itself
+type fakeImporter struct {
+	name   string
+	prefix string
+}
+
+func (f fakeImporter) Name() string {
+	return f.name
+}
+
+func (f fakeImporter) Detect(head []byte) bool {
+	return bytes.HasPrefix(head, []byte(f.prefix))
+}
+
+func (f fakeImporter) Parse(r io.Reader) (Result, error) {
+	return Result{Positions: []Position{{Symbol: f.name}}}, nil
+}
+
+func init() {
+	// Registered out of order, detection goes by name
+	Register(fakeImporter{name: "test-b", prefix: "FAKE"})
+	Register(fakeImporter{name: "test-a", prefix: "FAKE"})
+	Register(fakeImporter{name: "test-c", prefix: "OTHER"})
+}
+
+func writeExport(t *testing.T, contents string) string {
+	f, err := ioutil.TempFile("", "export*.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(contents); err != nil {
+		t.Fatal(err)
+	}
+
+	return f.Name()
+}
+
+func TestImportFile(t *testing.T) {
+	fake := writeExport(t, "FAKE export\n")
+	defer os.Remove(fake)
+	other := writeExport(t, "OTHER export\n")
+	defer os.Remove(other)
+	unknown := writeExport(t, "Nobody's export\n")
+	defer os.Remove(unknown)
+
+	tests := []struct {
+		name     string
+		file     string
+		importer string
+		expected string
+		err      string
+	}{
+		{name: "first detected by name", file: fake, expected: "test-a"},
+		{name: "only one detects", file: other, expected: "test-c"},
+		{name: "broker override", file: fake, importer: "test-b", expected: "test-b"},
+		{name: "override skips detection", file: unknown, importer: "test-c", expected: "test-c"},
+		{name: "unknown broker", file: fake, importer: "nope", err: "unknown importer: nope"},
+		{name: "unclaimed file", file: unknown, err: ErrUnknownFormat.Error()},
+	}
+
+	for _, test := range tests {
+		result, err := ImportFile(test.file, test.importer)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: expected error %q, got %v", test.name, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+
+		if result.Importer != test.expected || len(result.Positions) != 1 || result.Positions[0].Symbol != test.expected {
+			t.Errorf("%s: expected the %s importer, got %+v", test.name, test.expected, result)
+		}
+		if result.File != test.file {
+			t.Errorf("%s: expected the result to be for %s, got %s", test.name, test.file, result.File)
+		}
+	}
+}
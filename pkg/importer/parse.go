@@ -0,0 +1,98 @@
+package importer
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/samkreter/portfoli/pkg/money"
+)
+
+// ParseAmount parses a number as brokerages print it, e.g. "$1,234.56",
+// "-C$12.00", "(12.00)", "1,234.56 CAD" or "+3.5%". Blank values and placeholders like "--"
+// and "n/a" parse as 0.
+func ParseAmount(raw string) (float64, error) {
+	s, negative, ok := normalizeAmount(raw)
+	if !ok {
+		return 0, nil
+	}
+
+	val, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	if negative {
+		val = -val
+	}
+
+	return val, nil
+}
+
+// ParseMoney parses an amount of money like ParseAmount, exactly
+func ParseMoney(raw string) (money.Money, error) {
+	s, negative, ok := normalizeAmount(raw)
+	if !ok {
+		return 0, nil
+	}
+
+	val, err := money.Parse(s)
+	if err != nil {
+		return 0, err
+	}
+
+	if negative {
+		val = -val
+	}
+
+	return val, nil
+}
+
+// ParseCurrencyMoney parses an amount of money like ParseMoney along with the
+// currency it's printed in, empty when it has no symbol or code
+func ParseCurrencyMoney(raw string) (money.Money, money.Currency, error) {
+	_, currency := money.SplitCurrency(raw)
+
+	val, err := ParseMoney(raw)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return val, currency, nil
+}
+
+// normalizeAmount strips the currency symbols, separators and sign of raw,
+// ok is false for blank values and placeholders
+func normalizeAmount(raw string) (s string, negative bool, ok bool) {
+	s = strings.TrimSpace(raw)
+
+	switch strings.ToLower(s) {
+	case "", "--", "n/a", "na":
+		return "", false, false
+	}
+
+	s, _ = money.SplitCurrency(s)
+
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		negative = true
+		s = s[1 : len(s)-1]
+	}
+
+	s = strings.NewReplacer(",", "", "%", "", " ", "").Replace(s)
+	if strings.HasPrefix(s, "-") {
+		negative = !negative
+		s = s[1:]
+	}
+	s = strings.TrimPrefix(s, "+")
+
+	return s, negative, true
+}
+
+// IsBlankRow reports whether every cell of a csv row is empty
+func IsBlankRow(row []string) bool {
+	for _, cell := range row {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
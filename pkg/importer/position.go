@@ -0,0 +1,137 @@
+package importer
+
+import (
+	"time"
+
+	"github.com/samkreter/portfoli/pkg/money"
+)
+
+const (
+	// CashSymbol is used for cash balances that have no ticker of their own
+	CashSymbol = "CASH"
+	// PendingSymbol is used for activity that hasn't settled yet, e.g. unsettled trades or transfers
+	PendingSymbol = "PENDING"
+)
+
+// moneyMarketSymbols are the core and money market funds brokers sweep uninvested cash into
+var moneyMarketSymbols = map[string]bool{
+	"SPAXX": true,
+	"SPRXX": true,
+	"FDRXX": true,
+	"FZFXX": true,
+	"FCASH": true,
+	"CORE":  true,
+	"VMFXX": true,
+	"VMRXX": true,
+	"VUSXX": true,
+	"SWVXX": true,
+	"SNVXX": true,
+	"SNSXX": true,
+}
+
+// Position is a broker neutral holding of a single symbol in a single account
+type Position struct {
+	Account       string
+	Symbol        string
+	Description   string
+	Quantity      float64
+	LastPrice     money.Money
+	Value         money.Money
+	CostBasis     money.Money
+	TotalGainLoss money.Money
+	Type          string
+
+	// Currency is the ISO currency of the position's amounts
+	Currency money.Currency
+	// Native holds the amounts as imported once they're converted to another currency
+	Native *NativeAmounts
+
+	// Cash is set by importers for positions the export marks as cash, e.g. Fidelity's core position
+	Cash bool
+
+	// Lots are the tax lots making up the position, when they were imported
+	Lots []Lot
+}
+
+// NativeAmounts are the amounts of a position in the currency it was imported in
+type NativeAmounts struct {
+	Currency  money.Currency
+	LastPrice money.Money
+	Value     money.Money
+}
+
+// IsCash reports whether the position is cash, a money market fund or pending activity
+func (p Position) IsCash() bool {
+	return p.Cash || p.Symbol == CashSymbol || p.Symbol == PendingSymbol || moneyMarketSymbols[p.Symbol]
+}
+
+// Lot is a tax lot, shares of a symbol bought together at the same cost
+type Lot struct {
+	Account      string
+	Symbol       string
+	Acquired     time.Time
+	Quantity     float64
+	CostPerShare money.Money
+	Currency     money.Currency
+}
+
+// Result holds everything an importer got out of a single export
+type Result struct {
+	// File and Importer are the export and the name of the importer that parsed it
+	File     string
+	Importer string
+
+	Positions    []Position
+	Lots         []Lot
+	Transactions []Transaction
+
+	// Diagnostics are the rows that were skipped or rejected and other problems, in export order
+	Diagnostics []Diagnostic
+}
+
+// setDefaultCurrency puts everything that wasn't imported with a currency in currency
+func (r *Result) setDefaultCurrency(currency money.Currency) {
+	for idx := range r.Positions {
+		if r.Positions[idx].Currency == "" {
+			r.Positions[idx].Currency = currency
+		}
+	}
+	for idx := range r.Lots {
+		if r.Lots[idx].Currency == "" {
+			r.Lots[idx].Currency = currency
+		}
+	}
+	for idx := range r.Transactions {
+		if r.Transactions[idx].Currency == "" {
+			r.Transactions[idx].Currency = currency
+		}
+	}
+}
+
+// TransactionType is the kind of an account transaction
+type TransactionType string
+
+const (
+	Buy          = TransactionType("buy")
+	Sell         = TransactionType("sell")
+	Reinvestment = TransactionType("reinvestment")
+	Dividend     = TransactionType("dividend")
+	Other        = TransactionType("other")
+)
+
+// Transaction is an entry in an account's activity history
+type Transaction struct {
+	Account  string
+	Date     time.Time
+	Type     TransactionType
+	Symbol   string
+	Quantity float64
+	Price    money.Money
+	Amount   money.Money
+	Currency money.Currency
+}
+
+// IsPurchase reports whether the transaction acquired shares, including reinvested dividends
+func (t Transaction) IsPurchase() bool {
+	return t.Type == Buy || t.Type == Reinvestment
+}
@@ -0,0 +1,217 @@
+package money
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Currency is an ISO 4217 currency code, e.g. "USD"
+type Currency string
+
+// USD is the currency of exports that don't say which one they're in
+const USD = Currency("USD")
+
+// currencySymbols are the symbols exports print amounts with, the longer
+// symbols come first so "C$" isn't read as "$". A bare "$" is a US dollar.
+var currencySymbols = []struct {
+	symbol   string
+	currency Currency
+}{
+	{"US$", "USD"},
+	{"CA$", "CAD"},
+	{"AU$", "AUD"},
+	{"NZ$", "NZD"},
+	{"HK$", "HKD"},
+	{"C$", "CAD"},
+	{"A$", "AUD"},
+	{"S$", "SGD"},
+	{"€", "EUR"},
+	{"£", "GBP"},
+	{"¥", "JPY"},
+	{"$", "USD"},
+}
+
+// ParseCurrency parses an ISO code like "cad" or a symbol like "C$"
+func ParseCurrency(s string) (Currency, error) {
+	s = strings.TrimSpace(s)
+	if isCode(strings.ToUpper(s)) {
+		return Currency(strings.ToUpper(s)), nil
+	}
+
+	for _, known := range currencySymbols {
+		if s == known.symbol {
+			return known.currency, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown currency %q", s)
+}
+
+// SplitCurrency takes the currency symbol or ISO code off an amount like
+// "-C$1,234.56" or "1,234.56 CAD". The currency is empty when raw has none.
+func SplitCurrency(raw string) (string, Currency) {
+	s := strings.TrimSpace(raw)
+
+	if fields := strings.Fields(s); len(fields) == 2 {
+		if code := strings.ToUpper(fields[0]); isCode(code) {
+			return fields[1], Currency(code)
+		}
+		if code := strings.ToUpper(fields[1]); isCode(code) {
+			return fields[0], Currency(code)
+		}
+	}
+
+	for _, known := range currencySymbols {
+		if strings.Contains(s, known.symbol) {
+			return strings.TrimSpace(strings.Replace(s, known.symbol, "", 1)), known.currency
+		}
+	}
+
+	return s, ""
+}
+
+// String returns the ISO code
+func (c Currency) String() string {
+	return string(c)
+}
+
+// Set parses s into the currency, so currencies can be used as flags
+func (c *Currency) Set(s string) error {
+	parsed, err := ParseCurrency(s)
+	if err != nil {
+		return err
+	}
+	*c = parsed
+	return nil
+}
+
+// Rates is a table of exchange rates, each currency's rate is what one unit
+// of it is worth in the quote currency
+type Rates struct {
+	AsOf  time.Time
+	Quote Currency
+	Rates map[Currency]float64
+}
+
+// ratesFile is the JSON layout of a rates file
+type ratesFile struct {
+	AsOf  string               `json:"asOf"`
+	Quote Currency             `json:"quote"`
+	Rates map[Currency]float64 `json:"rates"`
+}
+
+// LoadRates reads an exchange rates file like
+// {"asOf": "2024-01-31", "quote": "USD", "rates": {"CAD": 0.7441}}
+func LoadRates(path string) (Rates, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Rates{}, err
+	}
+
+	var file ratesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return Rates{}, fmt.Errorf("failed to parse FX rates file %q: %v", path, err)
+	}
+
+	asOf, err := time.Parse("2006-01-02", file.AsOf)
+	if err != nil {
+		return Rates{}, fmt.Errorf("FX rates file %q: invalid asOf date %q, should be YYYY-MM-DD", path, file.AsOf)
+	}
+
+	quote, err := ParseCurrency(string(file.Quote))
+	if err != nil {
+		return Rates{}, fmt.Errorf("FX rates file %q: quote: %v", path, err)
+	}
+
+	rates := Rates{AsOf: asOf, Quote: quote, Rates: map[Currency]float64{}}
+	for code, rate := range file.Rates {
+		currency, err := ParseCurrency(string(code))
+		if err != nil {
+			return Rates{}, fmt.Errorf("FX rates file %q: %v", path, err)
+		}
+		if rate <= 0 {
+			return Rates{}, fmt.Errorf("FX rates file %q: %s has a non positive rate", path, currency)
+		}
+		rates.Rates[currency] = rate
+	}
+
+	return rates, nil
+}
+
+// Rate returns what one unit of from is worth in to
+func (r Rates) Rate(from, to Currency) (float64, error) {
+	if from == to {
+		return 1, nil
+	}
+
+	fromRate, err := r.quoteRate(from)
+	if err != nil {
+		return 0, err
+	}
+	toRate, err := r.quoteRate(to)
+	if err != nil {
+		return 0, err
+	}
+
+	return fromRate / toRate, nil
+}
+
+// Convert converts an amount in from to to
+func (r Rates) Convert(m Money, from, to Currency) (Money, error) {
+	rate, err := r.Rate(from, to)
+	if err != nil {
+		return 0, err
+	}
+
+	return m.Mul(rate), nil
+}
+
+// Missing returns the currencies, sorted, that can't be converted to base
+func (r Rates) Missing(currencies []Currency, base Currency) []Currency {
+	seen := map[Currency]bool{}
+	missing := []Currency{}
+	for _, currency := range currencies {
+		if seen[currency] {
+			continue
+		}
+		seen[currency] = true
+
+		if _, err := r.Rate(currency, base); err != nil {
+			missing = append(missing, currency)
+		}
+	}
+
+	sort.Slice(missing, func(i, j int) bool {
+		return missing[i] < missing[j]
+	})
+
+	return missing
+}
+
+func (r Rates) quoteRate(c Currency) (float64, error) {
+	if c == r.Quote {
+		return 1, nil
+	}
+
+	rate, ok := r.Rates[c]
+	if !ok {
+		return 0, fmt.Errorf("no %s exchange rate", c)
+	}
+	return rate, nil
+}
+
+func isCode(s string) bool {
+	if len(s) != 3 {
+		return false
+	}
+	for _, r := range s {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,356 @@
+// Package money is an exact decimal type for currency amounts.
+//
+// Amounts are held to a ten-thousandth of a unit so per share prices and NAVs
+// keep their precision. They are rounded to the cent, half away from zero,
+// wherever they become a trade, a total or printed output. Splitting an amount
+// across assets hands out whole cents so the parts add up to the amount
+// exactly. Share quantities aren't money, they stay float64 and are rounded by
+// the orders, down for buys and up for sells.
+package money
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount of currency in ten-thousandths of a unit
+type Money int64
+
+const (
+	// Cent is the smallest amount trades and totals are rounded to
+	Cent = Money(100)
+	// Unit is one dollar, euro, etc.
+	Unit = Money(scale)
+
+	scale      = 10000
+	fracDigits = 4
+)
+
+// Parse parses a plain decimal number like "-1234.5678" exactly, digits past
+// a ten-thousandth are rounded half away from zero
+func Parse(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+
+	negative := false
+	switch {
+	case strings.HasPrefix(s, "-"):
+		negative = true
+		s = s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+
+	whole, frac := s, ""
+	if idx := strings.IndexByte(s, '.'); idx >= 0 {
+		whole, frac = s[:idx], s[idx+1:]
+	}
+	if whole == "" && frac == "" {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	if !isDigits(whole) || !isDigits(frac) {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+
+	roundUp := false
+	if len(frac) > fracDigits {
+		roundUp = frac[fracDigits] >= '5'
+		frac = frac[:fracDigits]
+	}
+	frac += strings.Repeat("0", fracDigits-len(frac))
+
+	var units, fraction int64
+	var err error
+	if whole != "" {
+		units, err = strconv.ParseInt(whole, 10, 64)
+		if err != nil || units > math.MaxInt64/scale {
+			return 0, fmt.Errorf("invalid amount %q", s)
+		}
+	}
+	fraction, err = strconv.ParseInt(frac, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+
+	m := Money(units*scale + fraction)
+	if roundUp {
+		m++
+	}
+	if negative {
+		m = -m
+	}
+
+	return m, nil
+}
+
+// MustParse is Parse for constants, it panics on invalid amounts
+func MustParse(s string) Money {
+	m, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// FromFloat converts f to the nearest ten-thousandth
+func FromFloat(f float64) Money {
+	return Money(math.Round(f * scale))
+}
+
+// Float64 returns the amount as a float, for ratios and percents
+func (m Money) Float64() float64 {
+	return float64(m) / scale
+}
+
+// Round rounds the amount to the cent, half away from zero
+func (m Money) Round() Money {
+	rem := m % Cent
+	switch {
+	case rem >= Cent/2:
+		return m - rem + Cent
+	case rem <= -Cent/2:
+		return m - rem - Cent
+	default:
+		return m - rem
+	}
+}
+
+// Mul returns the amount times f, e.g. a percent of a total or shares times a
+// price. The product is exact and rounded once to the nearest ten-thousandth.
+// It panics if f isn't finite.
+func (m Money) Mul(f float64) Money {
+	r := new(big.Rat).SetFloat64(f)
+	if r == nil {
+		panic(fmt.Sprintf("money: multiplying by %v", f))
+	}
+
+	return m.mulRat(r)
+}
+
+// Div returns the amount divided by f, rounded once to the nearest
+// ten-thousandth. It panics if f is 0 or isn't finite.
+func (m Money) Div(f float64) Money {
+	r := new(big.Rat).SetFloat64(f)
+	if r == nil || r.Sign() == 0 {
+		panic(fmt.Sprintf("money: dividing by %v", f))
+	}
+
+	return m.mulRat(r.Inv(r))
+}
+
+// mulRat returns the amount times r rounded to the nearest ten-thousandth,
+// half away from zero
+func (m Money) mulRat(r *big.Rat) Money {
+	product := new(big.Rat).Mul(new(big.Rat).SetInt64(int64(m)), r)
+
+	// QuoRem truncates towards zero, a remainder of at least half rounds away from it
+	quo, rem := new(big.Int).QuoRem(product.Num(), product.Denom(), new(big.Int))
+	if rem.Abs(rem).Lsh(rem, 1).Cmp(product.Denom()) >= 0 {
+		quo.Add(quo, big.NewInt(int64(product.Sign())))
+	}
+
+	return Money(quo.Int64())
+}
+
+// Ratio returns the amount as a fraction of total, 0 for a zero total
+func (m Money) Ratio(total Money) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(m) / float64(total)
+}
+
+// Abs returns the absolute amount
+func (m Money) Abs() Money {
+	if m < 0 {
+		return -m
+	}
+	return m
+}
+
+// Allocate splits the amount, rounded to the cent, proportionally to weights.
+// The parts are whole cents and add up to the rounded amount exactly, the
+// leftover cents go to the parts with the largest remainders.
+func (m Money) Allocate(weights []float64) []Money {
+	parts := make([]Money, len(weights))
+
+	totalWeight := 0.0
+	for _, weight := range weights {
+		totalWeight += weight
+	}
+	if totalWeight <= 0 {
+		return parts
+	}
+
+	cents := int64(m.Round() / Cent)
+	remainders := make([]float64, len(weights))
+	allocated := int64(0)
+	for idx, weight := range weights {
+		exact := float64(cents) * weight / totalWeight
+		whole := math.Trunc(exact)
+		parts[idx] = Money(whole) * Cent
+		remainders[idx] = math.Abs(exact - whole)
+		allocated += int64(whole)
+	}
+
+	order := make([]int, len(weights))
+	for idx := range order {
+		order[idx] = idx
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return remainders[order[i]] > remainders[order[j]]
+	})
+
+	step := Cent
+	leftover := cents - allocated
+	if leftover < 0 {
+		step, leftover = -Cent, -leftover
+	}
+	for idx := int64(0); idx < leftover; idx++ {
+		parts[order[idx%int64(len(order))]] += step
+	}
+
+	return parts
+}
+
+// String formats the amount rounded to the cent, e.g. "-1234.56"
+func (m Money) String() string {
+	return m.decimal(2)
+}
+
+// Format implements fmt.Formatter. %f rounds the amount half away from zero
+// to the precision, the cent by default, without going through a float, so
+// "%.2f" prints amounts like float64 did. Every other verb prints String.
+func (m Money) Format(f fmt.State, verb rune) {
+	s := m.String()
+	if verb == 'f' || verb == 'F' {
+		prec, ok := f.Precision()
+		if !ok {
+			prec = 2
+		}
+		s = m.decimal(prec)
+	}
+
+	if f.Flag('+') && m >= 0 {
+		s = "+" + s
+	}
+	if width, ok := f.Width(); ok && len(s) < width {
+		padding := strings.Repeat(" ", width-len(s))
+		if f.Flag('-') {
+			s += padding
+		} else {
+			s = padding + s
+		}
+	}
+
+	fmt.Fprint(f, s)
+}
+
+// decimal formats the amount rounded half away from zero to prec decimals
+func (m Money) decimal(prec int) string {
+	digits := prec
+	if digits > fracDigits {
+		digits = fracDigits
+	}
+
+	step := Money(math.Pow10(fracDigits - digits))
+	rounded := m.Abs()
+	rounded = (rounded + step/2) / step * step
+
+	sign := ""
+	if m < 0 && rounded != 0 {
+		sign = "-"
+	}
+
+	s := fmt.Sprintf("%s%d", sign, int64(rounded/Unit))
+	if prec > 0 {
+		frac := fmt.Sprintf("%04d", int64(rounded%Unit))[:digits]
+		s += "." + frac + strings.Repeat("0", prec-digits)
+	}
+
+	return s
+}
+
+// exact formats the amount with every significant digit
+func (m Money) exact() string {
+	sign := ""
+	if m < 0 {
+		sign = "-"
+		m = -m
+	}
+
+	s := fmt.Sprintf("%s%d.%04d", sign, int64(m/Unit), int64(m%Unit))
+	return strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+}
+
+// MarshalJSON writes the amount as an exact JSON number
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.exact()), nil
+}
+
+// UnmarshalJSON reads a JSON number exactly, without going through a float
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if strings.ContainsAny(s, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("invalid amount %q", s)
+		}
+		*m = FromFloat(f)
+		return nil
+	}
+
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Set parses s into the amount, so amounts can be used as flags
+func (m *Money) Set(s string) error {
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Sum adds up amounts
+func Sum(amounts ...Money) Money {
+	total := Money(0)
+	for _, amount := range amounts {
+		total += amount
+	}
+	return total
+}
+
+// Max returns the larger amount
+func Max(a, b Money) Money {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// Min returns the smaller amount
+func Min(a, b Money) Money {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
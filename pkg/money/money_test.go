@@ -0,0 +1,128 @@
+package money
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := map[string]Money{
+		"1234.56":  12345600,
+		"-0.1":     -1000,
+		"+3":       30000,
+		".5":       5000,
+		"1.23455":  12346,
+		"-1.23455": -12346,
+	}
+	for raw, expected := range tests {
+		m, err := Parse(raw)
+		if err != nil {
+			t.Errorf("%q: %v", raw, err)
+			continue
+		}
+		if m != expected {
+			t.Errorf("%q: expected %d, got %d", raw, expected, m)
+		}
+	}
+
+	for _, raw := range []string{"", "-", "1.2.3", "1e5", "$1"} {
+		if _, err := Parse(raw); err == nil {
+			t.Errorf("%q: expected error", raw)
+		}
+	}
+}
+
+func TestMulDivRoundOnce(t *testing.T) {
+	tests := []struct {
+		name     string
+		got      Money
+		expected Money
+	}{
+		{"shares times price", MustParse("150.0001").Mul(3), MustParse("450.0003")},
+		{"half away from zero", Money(5).Mul(.5), Money(3)},
+		{"negative half away from zero", Money(-5).Mul(.5), Money(-3)},
+		{"beyond float precision", Money(123456789012345678).Mul(1), Money(123456789012345678)},
+		{"percent of a large total", Money(900719925474099300).Mul(.5), Money(450359962737049650)},
+		{"divide by a percent", MustParse("333.34").Div(.25), MustParse("1333.36")},
+		{"divide beyond float precision", Money(123456789012345678).Div(2), Money(61728394506172839)},
+		{"divide rounds half away from zero", Money(-3).Div(2), Money(-2)},
+	}
+
+	for _, test := range tests {
+		if test.got != test.expected {
+			t.Errorf("%s: expected %d, got %d", test.name, int64(test.expected), int64(test.got))
+		}
+	}
+}
+
+func TestAllocateAddsUpExactly(t *testing.T) {
+	parts := MustParse("100").Allocate([]float64{1, 1, 1})
+
+	expected := []Money{MustParse("33.34"), MustParse("33.33"), MustParse("33.33")}
+	for idx := range expected {
+		if parts[idx] != expected[idx] {
+			t.Errorf("part %d: expected %s, got %s", idx, expected[idx], parts[idx])
+		}
+	}
+
+	if sum := Sum(MustParse("-10").Allocate([]float64{.5, .25, .25})...); sum != MustParse("-10") {
+		t.Errorf("expected the parts to add up to -10, got %s", sum)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	m := MustParse("-1234.565")
+	tests := []struct {
+		got, expected string
+	}{
+		{fmt.Sprint(m), "-1234.57"},
+		{fmt.Sprintf("%.2f", m), "-1234.57"},
+		{fmt.Sprintf("%.0f", m), "-1235"},
+		{fmt.Sprintf("%.4f", m), "-1234.5650"},
+		{fmt.Sprintf("%+.2f", m.Abs()), "+1234.57"},
+		{fmt.Sprintf("%8.1f", Money(500)), "     0.1"},
+	}
+	for _, test := range tests {
+		if test.got != test.expected {
+			t.Errorf("expected %q, got %q", test.expected, test.got)
+		}
+	}
+}
+
+func TestSplitCurrency(t *testing.T) {
+	tests := []struct {
+		raw, amount string
+		currency    Currency
+	}{
+		{"-$1,234.56", "-1,234.56", USD},
+		{"C$12.00", "12.00", "CAD"},
+		{"(€3.50)", "(3.50)", "EUR"},
+		{"1,234.56 cad", "1,234.56", "CAD"},
+		{"12.00", "12.00", ""},
+	}
+	for _, test := range tests {
+		amount, currency := SplitCurrency(test.raw)
+		if amount != test.amount || currency != test.currency {
+			t.Errorf("%q: expected %q %q, got %q %q", test.raw, test.amount, test.currency, amount, currency)
+		}
+	}
+}
+
+func TestRatesConvert(t *testing.T) {
+	rates := Rates{Quote: USD, Rates: map[Currency]float64{"CAD": .75, "EUR": 1.5}}
+
+	converted, err := rates.Convert(MustParse("100"), "CAD", USD)
+	if err != nil || converted != MustParse("75") {
+		t.Errorf("expected 75.00 USD, got %s %v", converted, err)
+	}
+
+	// Neither side is the quote currency
+	converted, err = rates.Convert(MustParse("100"), "EUR", "CAD")
+	if err != nil || converted != MustParse("200") {
+		t.Errorf("expected 200.00 CAD, got %s %v", converted, err)
+	}
+
+	if missing := rates.Missing([]Currency{"GBP", USD, "CAD", "GBP"}, USD); len(missing) != 1 || missing[0] != "GBP" {
+		t.Errorf("expected GBP to be missing, got %v", missing)
+	}
+}
@@ -0,0 +1,221 @@
+package ofx
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/samkreter/portfoli/pkg/importer"
+	"github.com/samkreter/portfoli/pkg/money"
+)
+
+const (
+	importerName = "ofx"
+)
+
+// positionTypes maps the INVPOSLIST aggregates to a position type
+var positionTypes = map[string]string{
+	"POSSTOCK": "Stock",
+	"POSMF":    "Mutual Fund",
+	"POSDEBT":  "Debt",
+	"POSOPT":   "Option",
+	"POSOTHER": "Other",
+}
+
+// securityInfos are the SECLIST aggregates describing a security
+var securityInfos = []string{"STOCKINFO", "MFINFO", "DEBTINFO", "OPTINFO", "OTHERINFO"}
+
+func init() {
+	importer.Register(Importer{})
+}
+
+// Importer imports OFX 1.x (SGML) and 2.x (XML) investment statements,
+// including .qfx downloads
+type Importer struct{}
+
+// Name returns the name of the importer
+func (Importer) Name() string {
+	return importerName
+}
+
+// Detect reports whether head is the start of an OFX document
+func (Importer) Detect(head []byte) bool {
+	upper := bytes.ToUpper(head)
+	return bytes.Contains(upper, []byte("OFXHEADER")) || bytes.Contains(upper, []byte("<OFX>"))
+}
+
+// Parse reads the positions and available cash of every investment statement
+// in an OFX document
+func (Importer) Parse(r io.Reader) (importer.Result, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return importer.Result{}, err
+	}
+
+	root, err := parse(string(data))
+	if err != nil {
+		return importer.Result{}, err
+	}
+
+	result := importer.Result{}
+	securities := parseSecurities(root)
+
+	statements := root.findAll("INVSTMTRS")
+	if len(statements) == 0 {
+		result.Warn(0, "no investment statements (INVSTMTRS) found")
+	}
+
+	for _, stmt := range statements {
+		account := stmt.value("INVACCTFROM", "ACCTID")
+
+		// CURDEF is the statement's default currency, left to the importer's default when it's missing
+		var currency money.Currency
+		if curDef := stmt.child("CURDEF"); curDef != nil && curDef.Value != "" {
+			var err error
+			currency, err = money.ParseCurrency(curDef.Value)
+			if err != nil {
+				result.Warn(curDef.Line, "account %s: %v", account, err)
+			}
+		}
+
+		if posList := stmt.child("INVPOSLIST"); posList != nil {
+			for _, pos := range posList.Children {
+				position, err := parsePosition(pos, securities)
+				if err != nil {
+					result.Reject(pos.Line, inAccount(account, err))
+					continue
+				}
+				position.Account = account
+				if position.Currency == "" {
+					position.Currency = currency
+				}
+
+				result.Positions = append(result.Positions, position)
+			}
+		}
+
+		if availCash := stmt.path("INVBAL", "AVAILCASH"); availCash != nil && availCash.Value != "" {
+			cash, err := importer.ParseMoney(availCash.Value)
+			if err != nil {
+				result.Reject(availCash.Line, inAccount(account, &importer.FieldError{Column: "AVAILCASH", Raw: availCash.Value, Err: err}))
+				continue
+			}
+
+			result.Positions = append(result.Positions, importer.Position{
+				Account:     account,
+				Symbol:      importer.CashSymbol,
+				Description: "Available cash",
+				Quantity:    cash.Float64(),
+				LastPrice:   money.Unit,
+				Value:       cash,
+				Type:        "Cash",
+				Currency:    currency,
+			})
+		}
+	}
+
+	return result, nil
+}
+
+// inAccount adds the statement's account to err so its diagnostic says where the problem is
+func inAccount(account string, err error) error {
+	if fieldErr, ok := err.(*importer.FieldError); ok {
+		return &importer.FieldError{Column: "account " + account + " " + fieldErr.Column, Raw: fieldErr.Raw, Err: fieldErr.Err}
+	}
+	return fmt.Errorf("account %s: %v", account, err)
+}
+
+type security struct {
+	ticker string
+	name   string
+}
+
+// parseSecurities maps the unique ids (usually CUSIPs) in SECLIST to their ticker and name
+func parseSecurities(root *node) map[string]security {
+	securities := map[string]security{}
+	for _, secList := range root.findAll("SECLIST") {
+		for _, info := range secList.Children {
+			if !isSecurityInfo(info.Name) {
+				continue
+			}
+
+			uniqueID := info.value("SECINFO", "SECID", "UNIQUEID")
+			securities[uniqueID] = security{
+				ticker: info.value("SECINFO", "TICKER"),
+				name:   info.value("SECINFO", "SECNAME"),
+			}
+		}
+	}
+	return securities
+}
+
+func isSecurityInfo(name string) bool {
+	for _, infoName := range securityInfos {
+		if name == infoName {
+			return true
+		}
+	}
+	return false
+}
+
+func parsePosition(pos *node, securities map[string]security) (importer.Position, error) {
+	posType, ok := positionTypes[pos.Name]
+	if !ok {
+		return importer.Position{}, fmt.Errorf("unknown position type %s", pos.Name)
+	}
+
+	invPos := pos.child("INVPOS")
+	if invPos == nil {
+		return importer.Position{}, fmt.Errorf("%s is missing INVPOS", pos.Name)
+	}
+
+	uniqueID := invPos.value("SECID", "UNIQUEID")
+	sec, ok := securities[uniqueID]
+	symbol := sec.ticker
+	if !ok || symbol == "" {
+		// Fall back to the CUSIP so the holding is not lost
+		symbol = uniqueID
+	}
+
+	position := importer.Position{
+		Symbol:      strings.ToUpper(symbol),
+		Description: sec.name,
+		Type:        posType,
+	}
+
+	// A CURRENCY aggregate means the position's amounts aren't in the statement's currency
+	if curSym := invPos.value("CURRENCY", "CURSYM"); curSym != "" {
+		currency, err := money.ParseCurrency(curSym)
+		if err != nil {
+			return importer.Position{}, &importer.FieldError{Column: symbol + " CURSYM", Raw: curSym, Err: err}
+		}
+		position.Currency = currency
+	}
+
+	units := invPos.value("UNITS")
+	quantity, err := importer.ParseAmount(units)
+	if err != nil {
+		return importer.Position{}, &importer.FieldError{Column: symbol + " UNITS", Raw: units, Err: err}
+	}
+	position.Quantity = quantity
+
+	amounts := []struct {
+		element string
+		value   *money.Money
+	}{
+		{"UNITPRICE", &position.LastPrice},
+		{"MKTVAL", &position.Value},
+	}
+	for _, amount := range amounts {
+		raw := invPos.value(amount.element)
+		val, err := importer.ParseMoney(raw)
+		if err != nil {
+			return importer.Position{}, &importer.FieldError{Column: symbol + " " + amount.element, Raw: raw, Err: err}
+		}
+		*amount.value = val
+	}
+
+	return position, nil
+}
@@ -0,0 +1,118 @@
+package ofx
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/samkreter/portfoli/pkg/importer"
+	"github.com/samkreter/portfoli/pkg/money"
+)
+
+const sgmlStatement = `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+
+<OFX>
+<INVSTMTMSGSRSV1><INVSTMTTRNRS><TRNUID>1
+<INVSTMTRS>
+<INVACCTFROM><BROKERID>example.com<ACCTID>X123</INVACCTFROM>
+<INVPOSLIST>
+<POSSTOCK><INVPOS><SECID><UNIQUEID>922908769<UNIQUEIDTYPE>CUSIP</SECID><UNITS>100<UNITPRICE>150.00<MKTVAL>15000.00</INVPOS></POSSTOCK>
+</INVPOSLIST>
+<INVBAL><AVAILCASH>12.50</INVBAL>
+</INVSTMTRS>
+</INVSTMTTRNRS></INVSTMTMSGSRSV1>
+<SECLISTMSGSRSV1><SECLIST>
+<STOCKINFO><SECINFO><SECID><UNIQUEID>922908769<UNIQUEIDTYPE>CUSIP</SECID><SECNAME>TOTAL STOCK MKT<TICKER>VTI</SECINFO></STOCKINFO>
+</SECLIST></SECLISTMSGSRSV1>
+</OFX>
+`
+
+func TestParseSGML(t *testing.T) {
+	result, err := Importer{}.Parse(strings.NewReader(sgmlStatement))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Positions) != 2 {
+		t.Fatalf("expected 2 positions, got %d: %+v", len(result.Positions), result.Positions)
+	}
+
+	vti := result.Positions[0]
+	if vti.Symbol != "VTI" || vti.Account != "X123" || vti.Quantity != 100 || vti.LastPrice != money.MustParse("150") || vti.Value != money.MustParse("15000") {
+		t.Errorf("unexpected VTI position: %+v", vti)
+	}
+
+	cash := result.Positions[1]
+	if cash.Value != money.MustParse("12.5") {
+		t.Errorf("expected 12.5 available cash, got %s", cash.Value)
+	}
+}
+
+func TestParseXML(t *testing.T) {
+	doc := `<?xml version="1.0"?><?OFX OFXHEADER="200" VERSION="211"?>
+<OFX><INVSTMTMSGSRSV1><INVSTMTTRNRS><INVSTMTRS>
+<INVACCTFROM><ACCTID>999</ACCTID></INVACCTFROM>
+<INVPOSLIST><POSMF><INVPOS><SECID><UNIQUEID>ABC</UNIQUEID></SECID><UNITS>2.5</UNITS><UNITPRICE>10</UNITPRICE><MKTVAL>25</MKTVAL></INVPOS></POSMF></INVPOSLIST>
+</INVSTMTRS></INVSTMTTRNRS></INVSTMTMSGSRSV1></OFX>`
+
+	result, err := Importer{}.Parse(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Positions) != 1 {
+		t.Fatalf("expected 1 position, got %d", len(result.Positions))
+	}
+
+	// Without a SECLIST entry the unique id is used as the symbol
+	fund := result.Positions[0]
+	if fund.Symbol != "ABC" || fund.Account != "999" || fund.Quantity != 2.5 || fund.Value != money.MustParse("25") {
+		t.Errorf("unexpected position: %+v", fund)
+	}
+}
+
+func TestParseCurrency(t *testing.T) {
+	doc := `<OFX><INVSTMTMSGSRSV1><INVSTMTTRNRS><INVSTMTRS><CURDEF>CAD</CURDEF>
+<INVACCTFROM><ACCTID>TFSA</ACCTID></INVACCTFROM>
+<INVPOSLIST>
+<POSSTOCK><INVPOS><SECID><UNIQUEID>XIC</UNIQUEID></SECID><UNITS>10</UNITS><UNITPRICE>35</UNITPRICE><MKTVAL>350</MKTVAL></INVPOS></POSSTOCK>
+<POSSTOCK><INVPOS><SECID><UNIQUEID>VTI</UNIQUEID></SECID><UNITS>1</UNITS><UNITPRICE>250</UNITPRICE><MKTVAL>250</MKTVAL><CURRENCY><CURRATE>1.37</CURRATE><CURSYM>USD</CURSYM></CURRENCY></INVPOS></POSSTOCK>
+</INVPOSLIST>
+<INVBAL><AVAILCASH>5</AVAILCASH></INVBAL>
+</INVSTMTRS></INVSTMTTRNRS></INVSTMTMSGSRSV1></OFX>`
+
+	result, err := Importer{}.Parse(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []money.Currency{"CAD", money.USD, "CAD"}
+	if len(result.Positions) != len(expected) {
+		t.Fatalf("expected %d positions, got %d", len(expected), len(result.Positions))
+	}
+	for idx, position := range result.Positions {
+		if position.Currency != expected[idx] {
+			t.Errorf("%s: expected %s, got %q", position.Symbol, expected[idx], position.Currency)
+		}
+	}
+}
+
+func TestParseRejectsMalformedPosition(t *testing.T) {
+	doc := strings.Replace(sgmlStatement, "<UNITS>100", "<UNITS>1O0", 1)
+
+	result, err := Importer{}.Parse(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Positions) != 1 || result.Count(importer.Rejected) != 1 {
+		t.Fatalf("expected the VTI position to be rejected, got %+v", result)
+	}
+
+	// The POSSTOCK element is on line 10 of the statement
+	diagnostic := result.Diagnostics[0]
+	if diagnostic.Line != 10 || diagnostic.Column != "account X123 VTI UNITS" || diagnostic.Raw != "1O0" {
+		t.Errorf("unexpected diagnostic: %+v", diagnostic)
+	}
+}
@@ -0,0 +1,134 @@
+package ofx
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+)
+
+// node is an OFX element. Aggregates hold children, elements hold a value.
+type node struct {
+	Name     string
+	Value    string
+	Children []*node
+	// Line is the line of the document the element starts on, counting from 1
+	Line int
+}
+
+// child returns the first direct child named name
+func (n *node) child(name string) *node {
+	for _, c := range n.Children {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// path follows the first child for each name, returning nil if any is missing
+func (n *node) path(names ...string) *node {
+	curr := n
+	for _, name := range names {
+		if curr = curr.child(name); curr == nil {
+			return nil
+		}
+	}
+	return curr
+}
+
+// value returns the value at path or "" if it does not exist
+func (n *node) value(names ...string) string {
+	if c := n.path(names...); c != nil {
+		return c.Value
+	}
+	return ""
+}
+
+// findAll returns every descendant named name, not descending into matches
+func (n *node) findAll(name string) []*node {
+	found := []*node{}
+	for _, c := range n.Children {
+		if c.Name == name {
+			found = append(found, c)
+			continue
+		}
+		found = append(found, c.findAll(name)...)
+	}
+	return found
+}
+
+// parse builds the element tree of an OFX document. It handles OFX 1.x SGML,
+// where elements are not closed, as well as OFX 2.x XML. Headers and
+// processing instructions before the <OFX> root are skipped.
+func parse(doc string) (*node, error) {
+	start := strings.Index(strings.ToUpper(doc), "<OFX>")
+	if start < 0 {
+		return nil, errors.New("missing <OFX> root element")
+	}
+	line := 1 + strings.Count(doc[:start], "\n")
+	doc = doc[start:]
+
+	root := &node{}
+	stack := []*node{root}
+	// leaf is set while the top of the stack is an element that got a value
+	leaf := false
+
+	for len(doc) > 0 {
+		open := strings.IndexByte(doc, '<')
+		if open < 0 {
+			break
+		}
+
+		if text := strings.TrimSpace(doc[:open]); text != "" {
+			top := stack[len(stack)-1]
+			top.Value = html.UnescapeString(text)
+			leaf = true
+		}
+
+		end := strings.IndexByte(doc[open:], '>')
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated tag %q", doc[open:])
+		}
+		tag := strings.TrimSpace(doc[open+1 : open+end])
+		tagLine := line + strings.Count(doc[:open], "\n")
+		line = tagLine + strings.Count(doc[open:open+end+1], "\n")
+		doc = doc[open+end+1:]
+
+		switch {
+		case strings.HasPrefix(tag, "?"), strings.HasPrefix(tag, "!"):
+			continue
+		case strings.HasPrefix(tag, "/"):
+			name := strings.ToUpper(strings.TrimSpace(tag[1:]))
+			// Pop implicitly closed SGML elements until the matching open tag
+			for idx := len(stack) - 1; idx > 0; idx-- {
+				if stack[idx].Name == name {
+					stack = stack[:idx]
+					break
+				}
+			}
+			leaf = false
+		default:
+			// A new tag implicitly closes an unclosed SGML element
+			if leaf {
+				stack = stack[:len(stack)-1]
+				leaf = false
+			}
+
+			selfClosing := strings.HasSuffix(tag, "/")
+			n := &node{Name: strings.ToUpper(strings.TrimSpace(strings.TrimSuffix(tag, "/"))), Line: tagLine}
+			top := stack[len(stack)-1]
+			top.Children = append(top.Children, n)
+			if !selfClosing {
+				stack = append(stack, n)
+			}
+		}
+	}
+
+	ofxRoot := root.child("OFX")
+	if ofxRoot == nil {
+		return nil, errors.New("missing <OFX> root element")
+	}
+
+	return ofxRoot, nil
+}
@@ -0,0 +1,223 @@
+package schwab
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/samkreter/portfoli/pkg/importer"
+	"github.com/samkreter/portfoli/pkg/money"
+)
+
+const (
+	importerName = "schwab"
+
+	titlePrefix       = "Positions for"
+	titleAccountToken = "account "
+	titleAsOfToken    = " as of "
+
+	byteOrderMark = "\ufeff"
+
+	cashRowSymbol         = "Cash & Cash Investments"
+	accountTotalRowSymbol = "Account Total"
+)
+
+// columnAliases lists the header names Schwab has used for each column, the
+// first entry is the canonical name
+var columnAliases = map[string][]string{
+	"Symbol":        {"Symbol"},
+	"Description":   {"Description"},
+	"Quantity":      {"Quantity", "Qty (Quantity)"},
+	"Price":         {"Price"},
+	"Market Value":  {"Market Value", "Mkt Val (Market Value)"},
+	"Cost Basis":    {"Cost Basis"},
+	"Gain/Loss $":   {"Gain/Loss $", "Gain $ (Gain/Loss $)"},
+	"Security Type": {"Security Type"},
+}
+
+// requiredColumns are the columns a position can't be read without
+var requiredColumns = []string{"Symbol", "Market Value"}
+
+func init() {
+	importer.Register(Importer{})
+}
+
+// Importer imports Schwab "Positions" csv exports for a single account or All-Accounts
+type Importer struct{}
+
+// Name returns the name of the importer
+func (Importer) Name() string {
+	return importerName
+}
+
+// Detect reports whether head is the start of a Schwab positions export
+func (Importer) Detect(head []byte) bool {
+	head = bytes.TrimPrefix(head, []byte(byteOrderMark))
+	head = bytes.TrimLeft(head, `"`)
+
+	return bytes.HasPrefix(head, []byte(titlePrefix))
+}
+
+// Parse reads the positions of every account section in a Schwab export. The
+// "Account Total" summary rows are skipped and "Cash & Cash Investments" rows
+// become importer.CashSymbol positions.
+func (Importer) Parse(r io.Reader) (importer.Result, error) {
+	csvReader := csv.NewReader(r)
+	csvReader.FieldsPerRecord = -1
+	csvReader.LazyQuotes = true
+
+	result := importer.Result{}
+
+	account := ""
+	var columns map[string]int
+	line := 0
+	for {
+		row, err := csvReader.Read()
+		if err == io.EOF {
+			return result, nil
+		}
+		if err != nil {
+			return result, err
+		}
+		line++
+
+		if importer.IsBlankRow(row) {
+			continue
+		}
+
+		first := strings.TrimSpace(strings.TrimPrefix(row[0], byteOrderMark))
+		switch {
+		case strings.HasPrefix(first, titlePrefix):
+			account = parseTitleAccount(first)
+			continue
+		case first == "Symbol":
+			columns, err = parseHeader(row)
+			if err != nil {
+				return result, err
+			}
+			continue
+		case columns == nil || isSectionTitle(row):
+			// All-Accounts exports start each account with a line holding only its name
+			account = first
+			columns = nil
+			continue
+		case first == accountTotalRowSymbol:
+			result.Skip(line, first, "account total")
+			continue
+		}
+
+		position, err := parsePosition(row, columns)
+		if err != nil {
+			result.Reject(line, err)
+			continue
+		}
+		position.Account = account
+
+		result.Positions = append(result.Positions, position)
+	}
+}
+
+// parseTitleAccount gets the account from a title like
+// "Positions for account Individual ...123 as of 03:00 PM ET, 2020/05/01"
+func parseTitleAccount(title string) string {
+	idx := strings.Index(title, titleAccountToken)
+	if idx < 0 {
+		return ""
+	}
+
+	account := title[idx+len(titleAccountToken):]
+	if idx := strings.Index(account, titleAsOfToken); idx >= 0 {
+		account = account[:idx]
+	}
+
+	return strings.TrimSpace(account)
+}
+
+func isSectionTitle(row []string) bool {
+	return importer.IsBlankRow(row[1:])
+}
+
+// parseHeader maps the canonical column names to their index in the header
+// and errors when a required column is missing
+func parseHeader(row []string) (map[string]int, error) {
+	indexes := map[string]int{}
+	for idx, name := range row {
+		indexes[strings.TrimSpace(name)] = idx
+	}
+
+	columns := map[string]int{}
+	for canonical, aliases := range columnAliases {
+		for _, alias := range aliases {
+			if idx, ok := indexes[alias]; ok {
+				columns[canonical] = idx
+				break
+			}
+		}
+	}
+
+	missing := []string{}
+	for _, required := range requiredColumns {
+		if _, ok := columns[required]; !ok {
+			missing = append(missing, required)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("positions export is missing the %q columns, the header is %q", missing, row)
+	}
+
+	return columns, nil
+}
+
+func parsePosition(row []string, columns map[string]int) (importer.Position, error) {
+	get := func(name string) string {
+		idx, ok := columns[name]
+		if !ok || idx >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[idx])
+	}
+
+	position := importer.Position{
+		Symbol:      get("Symbol"),
+		Description: get("Description"),
+		Type:        get("Security Type"),
+	}
+
+	if position.Symbol == cashRowSymbol {
+		position.Symbol = importer.CashSymbol
+		position.Description = cashRowSymbol
+	}
+
+	quantity, err := importer.ParseAmount(get("Quantity"))
+	if err != nil {
+		return importer.Position{}, &importer.FieldError{Column: "Quantity", Raw: get("Quantity"), Err: err}
+	}
+	position.Quantity = quantity
+
+	amounts := []struct {
+		column string
+		value  *money.Money
+	}{
+		{"Price", &position.LastPrice},
+		{"Market Value", &position.Value},
+		{"Cost Basis", &position.CostBasis},
+		{"Gain/Loss $", &position.TotalGainLoss},
+	}
+	for _, amount := range amounts {
+		val, err := importer.ParseMoney(get(amount.column))
+		if err != nil {
+			return importer.Position{}, &importer.FieldError{Column: amount.column, Raw: get(amount.column), Err: err}
+		}
+		*amount.value = val
+	}
+
+	// Cash is its own price
+	if position.Symbol == importer.CashSymbol && position.Quantity == 0 {
+		position.Quantity = position.Value.Float64()
+		position.LastPrice = money.Unit
+	}
+
+	return position, nil
+}
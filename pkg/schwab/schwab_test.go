@@ -0,0 +1,84 @@
+package schwab
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/samkreter/portfoli/pkg/importer"
+	"github.com/samkreter/portfoli/pkg/money"
+)
+
+const header = `"Symbol","Description","Quantity","Price","Price Change %","Price Change $","Market Value","Day Change %","Day Change $","Cost Basis","Gain/Loss %","Gain/Loss $","Ratings","Reinvest Dividends?","Capital Gains?","% Of Account","Security Type",` + "\n"
+
+func TestParseAllAccounts(t *testing.T) {
+	export := byteOrderMark + `"Positions for All-Accounts as of 03:00 PM ET, 2020/05/01"` + "\n\n" +
+		`"Individual ...123"` + "\n" + header +
+		`"VTI","VANGUARD TOTAL STOCK MARKET ETF","100","$150.00","0.5%","$0.75","$15,000.00","0.5%","$75.00","$13,000.00","15.38%","$2,000.00","--","No","N/A","90%","ETFs & Closed End Funds",` + "\n" +
+		`"Cash & Cash Investments","--","--","--","--","--","$1,234.56","0%","$0.00","--","--","--","--","--","--","10%","Cash and Money Market",` + "\n" +
+		`"Account Total","--","--","--","--","--","$16,234.56","0.4%","$75.00","$13,000.00","15.38%","$2,000.00","--","--","--","--","--",` + "\n\n" +
+		`"Roth IRA ...456"` + "\n" + header +
+		`"VTI","VANGUARD TOTAL STOCK MARKET ETF","10","$150.00","0.5%","$0.75","$1,500.00","0.5%","$7.50","$1,300.00","15.38%","$200.00","--","No","N/A","100%","ETFs & Closed End Funds",` + "\n" +
+		`"Account Total","--","--","--","--","--","$1,500.00","0.4%","$75.00","$1,300.00","15.38%","$200.00","--","--","--","--","--",` + "\n"
+
+	if !(Importer{}).Detect([]byte(export)) {
+		t.Fatal("expected the export to be detected")
+	}
+
+	result, err := Importer{}.Parse(strings.NewReader(export))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []importer.Position{
+		{Account: "Individual ...123", Symbol: "VTI", Quantity: 100, Value: money.MustParse("15000")},
+		{Account: "Individual ...123", Symbol: importer.CashSymbol, Quantity: 1234.56, Value: money.MustParse("1234.56")},
+		{Account: "Roth IRA ...456", Symbol: "VTI", Quantity: 10, Value: money.MustParse("1500")},
+	}
+	if len(result.Positions) != len(expected) {
+		t.Fatalf("expected %d positions, got %+v", len(expected), result.Positions)
+	}
+	for idx, position := range result.Positions {
+		if position.Account != expected[idx].Account || position.Symbol != expected[idx].Symbol ||
+			position.Quantity != expected[idx].Quantity || position.Value != expected[idx].Value {
+			t.Errorf("expected %+v, got %+v", expected[idx], position)
+		}
+	}
+	if cash := result.Positions[1]; cash.LastPrice != money.Unit || cash.Description != cashRowSymbol {
+		t.Errorf("expected the cash row to be priced at 1, got %+v", cash)
+	}
+
+	if skipped := result.Count(importer.Skipped); skipped != 2 {
+		t.Errorf("expected both account total rows to be skipped, got %+v", result.Diagnostics)
+	}
+	if rejected := result.Count(importer.Rejected); rejected != 0 {
+		t.Errorf("expected nothing rejected, got %+v", result.Diagnostics)
+	}
+}
+
+func TestParseSingleAccountTitle(t *testing.T) {
+	export := `"Positions for account Individual ...123 as of 03:00 PM ET, 2020/05/01"` + "\n\n" + header +
+		`"VTI","VANGUARD TOTAL STOCK MARKET ETF","100","$150.00","0.5%","$0.75","$15,000.00","0.5%","$75.00","$13,000.00","15.38%","$2,000.00","--","No","N/A","100%","ETFs & Closed End Funds",` + "\n"
+
+	result, err := Importer{}.Parse(strings.NewReader(export))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Positions) != 1 || result.Positions[0].Account != "Individual ...123" {
+		t.Errorf("expected VTI in Individual ...123, got %+v", result.Positions)
+	}
+}
+
+func TestParseMissingColumns(t *testing.T) {
+	export := `"Positions for account Individual ...123 as of 03:00 PM ET, 2020/05/01"` + "\n\n" +
+		`"Symbol","Description","Quantity","Price"` + "\n" +
+		`"VTI","VANGUARD TOTAL STOCK MARKET ETF","100","$150.00"` + "\n"
+
+	_, err := Importer{}.Parse(strings.NewReader(export))
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if !strings.Contains(err.Error(), "Market Value") {
+		t.Errorf("expected %q to list the missing Market Value column", err)
+	}
+}
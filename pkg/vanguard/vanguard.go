@@ -0,0 +1,148 @@
+package vanguard
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"strings"
+
+	"github.com/samkreter/portfoli/pkg/importer"
+)
+
+const (
+	importerName = "vanguard"
+
+	accountNumberCol  = "Account Number"
+	investmentNameCol = "Investment Name"
+	symbolCol         = "Symbol"
+	sharesCol         = "Shares"
+	sharePriceCol     = "Share Price"
+	totalValueCol     = "Total Value"
+
+	// transactionsMarkerCol only appears in the header of the transactions section
+	transactionsMarkerCol = "Trade Date"
+)
+
+func init() {
+	importer.Register(Importer{})
+}
+
+// Importer imports Vanguard "Download holdings" csv exports
+type Importer struct{}
+
+// Name returns the name of the importer
+func (Importer) Name() string {
+	return importerName
+}
+
+// Detect reports whether head is the start of a Vanguard holdings export
+func (Importer) Detect(head []byte) bool {
+	firstLine := head
+	if idx := bytes.IndexByte(head, '\n'); idx >= 0 {
+		firstLine = head[:idx]
+	}
+
+	return bytes.HasPrefix(firstLine, []byte(accountNumberCol)) &&
+		bytes.Contains(firstLine, []byte(investmentNameCol)) &&
+		bytes.Contains(firstLine, []byte(totalValueCol))
+}
+
+// Parse reads the holdings from a Vanguard export, skipping the transactions section
+func (Importer) Parse(r io.Reader) (importer.Result, error) {
+	csvReader := csv.NewReader(r)
+	csvReader.FieldsPerRecord = -1
+	csvReader.LazyQuotes = true
+
+	result := importer.Result{}
+
+	// columns maps the holdings header names to their index, nil outside a holdings section
+	var columns map[string]int
+	line := 0
+	for {
+		row, err := csvReader.Read()
+		if err == io.EOF {
+			return result, nil
+		}
+		if err != nil {
+			return result, err
+		}
+		line++
+
+		if importer.IsBlankRow(row) {
+			columns = nil
+			continue
+		}
+
+		if strings.TrimSpace(row[0]) == accountNumberCol {
+			columns = parseHeader(row)
+			continue
+		}
+
+		if columns == nil {
+			result.Skip(line, row[0], "not in a holdings section")
+			continue
+		}
+
+		position, err := parseHolding(row, columns)
+		if err != nil {
+			result.Reject(line, err)
+			continue
+		}
+
+		result.Positions = append(result.Positions, position)
+	}
+}
+
+// parseHeader returns the column indexes for a holdings header, or nil for any other header
+func parseHeader(row []string) map[string]int {
+	columns := map[string]int{}
+	for idx, name := range row {
+		columns[strings.TrimSpace(name)] = idx
+	}
+
+	if _, ok := columns[transactionsMarkerCol]; ok {
+		return nil
+	}
+
+	for _, required := range []string{investmentNameCol, symbolCol, sharesCol, sharePriceCol, totalValueCol} {
+		if _, ok := columns[required]; !ok {
+			return nil
+		}
+	}
+
+	return columns
+}
+
+func parseHolding(row []string, columns map[string]int) (importer.Position, error) {
+	get := func(name string) string {
+		idx := columns[name]
+		if idx >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[idx])
+	}
+
+	shares, err := importer.ParseAmount(get(sharesCol))
+	if err != nil {
+		return importer.Position{}, &importer.FieldError{Column: sharesCol, Raw: get(sharesCol), Err: err}
+	}
+
+	sharePrice, err := importer.ParseMoney(get(sharePriceCol))
+	if err != nil {
+		return importer.Position{}, &importer.FieldError{Column: sharePriceCol, Raw: get(sharePriceCol), Err: err}
+	}
+
+	totalValue, err := importer.ParseMoney(get(totalValueCol))
+	if err != nil {
+		return importer.Position{}, &importer.FieldError{Column: totalValueCol, Raw: get(totalValueCol), Err: err}
+	}
+
+	return importer.Position{
+		Account:     get(accountNumberCol),
+		Symbol:      get(symbolCol),
+		Description: get(investmentNameCol),
+		Quantity:    shares,
+		LastPrice:   sharePrice,
+		Value:       totalValue,
+	}, nil
+}
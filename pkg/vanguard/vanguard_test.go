@@ -0,0 +1,55 @@
+package vanguard
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/samkreter/portfoli/pkg/importer"
+	"github.com/samkreter/portfoli/pkg/money"
+)
+
+func TestParseSkipsTransactions(t *testing.T) {
+	export := "Account Number,Investment Name,Symbol,Shares,Share Price,Total Value,\n" +
+		"12345678,VANGUARD TOTAL STOCK MARKET ETF,VTI,10.0000,150.00,1500.00,\n" +
+		"12345678,VANGUARD FEDERAL MONEY MARKET INVESTOR CL,VMFXX,250.12,1.00,250.12,\n" +
+		"\n\n\n" +
+		"Account Number,Trade Date,Settlement Date,Transaction Type,Transaction Description,Investment Name,Symbol,Shares,Share Price,Principal Amount,Commissions and Fees,Net Amount,Accrued Interest,Account Type,\n" +
+		"12345678,2020-04-01,2020-04-03,Buy,Buy,VANGUARD TOTAL STOCK MARKET ETF,VTI,2.0000,140.00,-280.00,0.0,-280.00,0.0,CASH,\n" +
+		"12345678,2020-03-02,2020-03-04,Sell,Sell,VANGUARD TOTAL STOCK MARKET ETF,VTI,-1.0000,145.00,145.00,0.0,145.00,0.0,CASH,\n"
+
+	if !(Importer{}).Detect([]byte(export)) {
+		t.Fatal("expected the export to be detected")
+	}
+
+	result, err := Importer{}.Parse(strings.NewReader(export))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []importer.Position{
+		{Account: "12345678", Symbol: "VTI", Quantity: 10, LastPrice: money.MustParse("150"), Value: money.MustParse("1500")},
+		{Account: "12345678", Symbol: "VMFXX", Quantity: 250.12, LastPrice: money.Unit, Value: money.MustParse("250.12")},
+	}
+	if len(result.Positions) != len(expected) {
+		t.Fatalf("expected %d positions, got %+v", len(expected), result.Positions)
+	}
+	for idx, position := range result.Positions {
+		if position.Account != expected[idx].Account || position.Symbol != expected[idx].Symbol || position.Quantity != expected[idx].Quantity ||
+			position.LastPrice != expected[idx].LastPrice || position.Value != expected[idx].Value {
+			t.Errorf("expected %+v, got %+v", expected[idx], position)
+		}
+	}
+
+	skipped := []int{}
+	for _, diagnostic := range result.Diagnostics {
+		if diagnostic.Severity != importer.Skipped {
+			t.Errorf("expected only skipped rows, got %s", diagnostic)
+			continue
+		}
+		skipped = append(skipped, diagnostic.Line)
+	}
+	// Blank lines aren't records, the transactions are the 5th and 6th
+	if len(skipped) != 2 || skipped[0] != 5 || skipped[1] != 6 {
+		t.Errorf("expected both transactions to be skipped, got %+v", result.Diagnostics)
+	}
+}
@@ -0,0 +1,66 @@
+package portfolio
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// TaxType is how an account is taxed
+type TaxType string
+
+const (
+	Taxable     = TaxType("taxable")
+	Traditional = TaxType("traditional")
+	Roth        = TaxType("roth")
+	HSA         = TaxType("hsa")
+)
+
+// TaxTypes returns all account tax types
+func TaxTypes() []TaxType {
+	return []TaxType{Taxable, Traditional, Roth, HSA}
+}
+
+// Account holds the metadata of an account, keyed by the account name in the imports
+type Account struct {
+	Name    string  `json:"name"`
+	TaxType TaxType `json:"taxType"`
+}
+
+// AccountsFile is the on-disk format of the account metadata
+type AccountsFile struct {
+	Accounts []Account `json:"accounts"`
+}
+
+// LoadAccounts reads the tax type of each account from a JSON accounts file
+func LoadAccounts(path string) (map[string]TaxType, error) {
+	taxTypes := map[string]TaxType{}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var accountsFile AccountsFile
+	if err := json.Unmarshal(data, &accountsFile); err != nil {
+		return nil, fmt.Errorf("failed to parse accounts file %q: %v", path, err)
+	}
+
+	for _, account := range accountsFile.Accounts {
+		if !isTaxType(account.TaxType) {
+			return nil, fmt.Errorf("account %q in %q has invalid tax type %q, should be one of %v", account.Name, path, account.TaxType, TaxTypes())
+		}
+		taxTypes[account.Name] = account.TaxType
+	}
+
+	return taxTypes, nil
+}
+
+func isTaxType(taxType TaxType) bool {
+	for _, t := range TaxTypes() {
+		if t == taxType {
+			return true
+		}
+	}
+	return false
+}
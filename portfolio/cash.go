@@ -0,0 +1,65 @@
+package portfolio
+
+import (
+	"github.com/samkreter/portfoli/pkg/importer"
+	"github.com/samkreter/portfoli/pkg/money"
+)
+
+// CashPositions returns the cash, money market and pending activity positions
+func (p *Portfolio) CashPositions() []importer.Position {
+	positions := []importer.Position{}
+	for _, position := range p.Positions {
+		if position.IsCash() {
+			positions = append(positions, position)
+		}
+	}
+
+	return positions
+}
+
+// Cash returns the cash available to invest across every account, pending
+// activity is included so unsettled buys aren't spent twice
+func (p *Portfolio) Cash() money.Money {
+	total := money.Money(0)
+	for _, position := range p.CashPositions() {
+		total += position.Value
+	}
+
+	return total
+}
+
+// CashExcluding returns the cash available to invest less the money market
+// funds in symbols, e.g. the ones an allocation plan holds as an asset
+func (p *Portfolio) CashExcluding(symbols []string) money.Money {
+	total := money.Money(0)
+	for _, position := range p.CashPositions() {
+		if !contains(symbols, position.Symbol) {
+			total += position.Value
+		}
+	}
+
+	return total
+}
+
+// AccountCash returns the cash available to invest in each account
+func (p *Portfolio) AccountCash() map[string]money.Money {
+	accountCash := map[string]money.Money{}
+	for _, position := range p.CashPositions() {
+		accountCash[position.Account] += position.Value
+	}
+
+	return accountCash
+}
+
+// AccountCashExcluding returns the cash available to invest in each account
+// less the money market funds in symbols
+func (p *Portfolio) AccountCashExcluding(symbols []string) map[string]money.Money {
+	accountCash := map[string]money.Money{}
+	for _, position := range p.CashPositions() {
+		if !contains(symbols, position.Symbol) {
+			accountCash[position.Account] += position.Value
+		}
+	}
+
+	return accountCash
+}
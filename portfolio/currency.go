@@ -0,0 +1,103 @@
+package portfolio
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/samkreter/portfoli/pkg/importer"
+	"github.com/samkreter/portfoli/pkg/money"
+)
+
+// Currencies returns the sorted currencies the portfolio's positions, lots
+// and transactions are in
+func (p *Portfolio) Currencies() []money.Currency {
+	seen := map[money.Currency]bool{}
+	currencies := []money.Currency{}
+	add := func(currency money.Currency) {
+		if currency != "" && !seen[currency] {
+			seen[currency] = true
+			currencies = append(currencies, currency)
+		}
+	}
+
+	for _, position := range p.Positions {
+		add(position.Currency)
+		for _, lot := range position.Lots {
+			add(lotCurrency(lot, position.Currency))
+		}
+	}
+	for _, transaction := range p.Transactions {
+		add(transaction.Currency)
+	}
+
+	sort.Slice(currencies, func(i, j int) bool {
+		return currencies[i] < currencies[j]
+	})
+
+	return currencies
+}
+
+// Convert converts every position, lot and transaction to base so values can
+// be added up across accounts. The imported amounts of converted positions are
+// kept in Native. Nothing is converted if rates is missing a currency.
+func (p *Portfolio) Convert(rates money.Rates, base money.Currency) error {
+	if missing := rates.Missing(p.Currencies(), base); len(missing) > 0 {
+		return fmt.Errorf("no exchange rates from %v to %s as of %s", missing, base, rates.AsOf.Format("2006-01-02"))
+	}
+
+	convert := func(m money.Money, from money.Currency) money.Money {
+		// The currencies were checked above
+		converted, _ := rates.Convert(m, from, base)
+		return converted
+	}
+
+	for idx := range p.Positions {
+		position := &p.Positions[idx]
+
+		// Lots are converted at today's rate, not the one they were bought at
+		for lotIdx := range position.Lots {
+			lot := &position.Lots[lotIdx]
+			lot.CostPerShare = convert(lot.CostPerShare, lotCurrency(*lot, position.Currency))
+			lot.Currency = base
+		}
+
+		if position.Currency == base {
+			continue
+		}
+
+		if position.Native == nil {
+			position.Native = &importer.NativeAmounts{
+				Currency:  position.Currency,
+				LastPrice: position.LastPrice,
+				Value:     position.Value,
+			}
+		}
+
+		position.LastPrice = convert(position.LastPrice, position.Currency)
+		position.Value = convert(position.Value, position.Currency)
+		position.CostBasis = convert(position.CostBasis, position.Currency)
+		position.TotalGainLoss = convert(position.TotalGainLoss, position.Currency)
+		position.Currency = base
+	}
+
+	for idx := range p.Transactions {
+		transaction := &p.Transactions[idx]
+		if transaction.Currency == base || transaction.Currency == "" {
+			continue
+		}
+
+		transaction.Price = convert(transaction.Price, transaction.Currency)
+		transaction.Amount = convert(transaction.Amount, transaction.Currency)
+		transaction.Currency = base
+	}
+
+	return nil
+}
+
+// lotCurrency returns the currency of the lot, the position's when the lot's export didn't have one
+func lotCurrency(lot importer.Lot, positionCurrency money.Currency) money.Currency {
+	if lot.Currency == "" {
+		return positionCurrency
+	}
+	return lot.Currency
+}
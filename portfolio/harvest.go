@@ -0,0 +1,78 @@
+package portfolio
+
+import (
+	"sort"
+	"time"
+
+	"github.com/samkreter/portfoli/pkg/money"
+)
+
+// HarvestCandidate is a position in a taxable account with an unrealized loss
+type HarvestCandidate struct {
+	Account string
+	Symbol  string
+
+	// Value is the value to sell, the losing lots or else the whole position
+	Value money.Money
+
+	// Loss is the unrealized loss, a negative amount
+	Loss money.Money
+
+	// Lots are the losing lots to sell, empty when the position has no imported lots
+	Lots []LotSale
+}
+
+// HarvestCandidates returns the positions in taxable accounts with an
+// unrealized loss of at least threshold, biggest loss first. Positions with
+// imported lots only count their losing lots, others use the position's cost
+// basis. taxTypes is the tax type of each account, unlisted accounts are taxable.
+func (p *Portfolio) HarvestCandidates(threshold money.Money, taxTypes map[string]TaxType, asOf time.Time) []HarvestCandidate {
+	candidates := []HarvestCandidate{}
+	for _, position := range p.Positions {
+		if taxType, ok := taxTypes[position.Account]; ok && taxType != Taxable {
+			continue
+		}
+		if position.IsCash() {
+			continue
+		}
+
+		candidate := HarvestCandidate{
+			Account: position.Account,
+			Symbol:  position.Symbol,
+		}
+
+		if len(position.Lots) > 0 {
+			for _, lot := range position.Lots {
+				gain := (position.LastPrice - lot.CostPerShare).Mul(lot.Quantity)
+				if gain >= 0 {
+					continue
+				}
+
+				candidate.Loss += gain
+				candidate.Value += position.LastPrice.Mul(lot.Quantity)
+				candidate.Lots = append(candidate.Lots, LotSale{
+					Lot:      lot,
+					Shares:   lot.Quantity,
+					Gain:     gain,
+					LongTerm: IsLongTerm(lot, asOf),
+				})
+			}
+		} else if position.CostBasis > 0 {
+			candidate.Value = position.Value
+			candidate.Loss = position.Value - position.CostBasis
+		} else {
+			candidate.Value = position.Value
+			candidate.Loss = position.TotalGainLoss
+		}
+
+		if -candidate.Loss >= threshold && candidate.Loss < 0 {
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Loss < candidates[j].Loss
+	})
+
+	return candidates
+}
@@ -0,0 +1,172 @@
+package portfolio
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/samkreter/portfoli/pkg/importer"
+	"github.com/samkreter/portfoli/pkg/money"
+)
+
+// LotMethod is how lots are picked when selling
+type LotMethod string
+
+const (
+	// HIFO sells the lots with the highest cost first
+	HIFO = LotMethod("hifo")
+	// FIFO sells the oldest lots first
+	FIFO = LotMethod("fifo")
+	// MinGain sells the lots with the smallest gain, or biggest loss, per share first
+	MinGain = LotMethod("min-gain")
+	// LongTermFirst sells long-term lots first, highest cost first within each term
+	LongTermFirst = LotMethod("long-term")
+)
+
+// LotMethods returns all lot selection methods
+func LotMethods() []LotMethod {
+	return []LotMethod{HIFO, FIFO, MinGain, LongTermFirst}
+}
+
+// LotSale is part of a lot picked for a sale
+type LotSale struct {
+	Lot      importer.Lot
+	Shares   float64
+	Gain     money.Money
+	LongTerm bool
+}
+
+// SaleEstimate is the lots picked to sell an amount of a symbol and the gains it realizes
+type SaleEstimate struct {
+	Symbol        string
+	Amount        money.Money
+	Sales         []LotSale
+	ShortTermGain money.Money
+	LongTermGain  money.Money
+
+	// Uncovered is the amount the imported lots couldn't cover
+	Uncovered money.Money
+
+	// Unpriced are the symbols whose lots were skipped because they have no price
+	Unpriced []string
+}
+
+// IsLongTerm reports whether a lot sold on the day of asOf qualifies for
+// long-term capital gains, it has to be held more than a year so a sale on
+// the anniversary of the purchase is still short-term
+func IsLongTerm(lot importer.Lot, asOf time.Time) bool {
+	return day(asOf).After(day(lot.Acquired).AddDate(1, 0, 0))
+}
+
+// Lots returns the tax lots of the symbols held in any account
+func (p *Portfolio) Lots(symbols ...string) []importer.Lot {
+	lots := []importer.Lot{}
+	for _, position := range p.Positions {
+		for _, symbol := range symbols {
+			if position.Symbol == symbol {
+				lots = append(lots, position.Lots...)
+			}
+		}
+	}
+
+	return lots
+}
+
+// SelectLots picks the lots of the symbols to sell amount using method and
+// estimates the short and long-term gains realized if sold at asOf. Each lot is
+// priced at prices of its own symbol, the lots of symbols without a price are
+// skipped and listed in Unpriced.
+func (p *Portfolio) SelectLots(symbols []string, amount money.Money, prices map[string]money.Money, method LotMethod, asOf time.Time) (SaleEstimate, error) {
+	estimate := SaleEstimate{
+		Symbol:    symbols[0],
+		Amount:    amount,
+		Uncovered: amount,
+	}
+
+	lots := []importer.Lot{}
+	for _, lot := range p.Lots(symbols...) {
+		if prices[lot.Symbol] <= 0 {
+			if !contains(estimate.Unpriced, lot.Symbol) {
+				estimate.Unpriced = append(estimate.Unpriced, lot.Symbol)
+			}
+			continue
+		}
+		lots = append(lots, lot)
+	}
+
+	if err := sortLots(lots, method, prices, asOf); err != nil {
+		return estimate, err
+	}
+
+	remaining := amount
+	for _, lot := range lots {
+		if remaining <= 0 {
+			break
+		}
+
+		price := prices[lot.Symbol]
+		shares := lot.Quantity
+		if wanted := remaining.Ratio(price); wanted <= shares {
+			shares = wanted
+			remaining = 0
+		} else {
+			remaining -= price.Mul(shares)
+		}
+
+		sale := LotSale{
+			Lot:      lot,
+			Shares:   shares,
+			Gain:     (price - lot.CostPerShare).Mul(shares),
+			LongTerm: IsLongTerm(lot, asOf),
+		}
+
+		if sale.LongTerm {
+			estimate.LongTermGain += sale.Gain
+		} else {
+			estimate.ShortTermGain += sale.Gain
+		}
+		estimate.Sales = append(estimate.Sales, sale)
+	}
+
+	estimate.Uncovered = money.Max(remaining, 0)
+
+	return estimate, nil
+}
+
+func sortLots(lots []importer.Lot, method LotMethod, prices map[string]money.Money, asOf time.Time) error {
+	var less func(a, b importer.Lot) bool
+	switch method {
+	case HIFO:
+		less = func(a, b importer.Lot) bool { return a.CostPerShare > b.CostPerShare }
+	case FIFO:
+		less = func(a, b importer.Lot) bool { return a.Acquired.Before(b.Acquired) }
+	case MinGain:
+		less = func(a, b importer.Lot) bool {
+			return (prices[a.Symbol] - a.CostPerShare) < (prices[b.Symbol] - b.CostPerShare)
+		}
+	case LongTermFirst:
+		less = func(a, b importer.Lot) bool {
+			if IsLongTerm(a, asOf) != IsLongTerm(b, asOf) {
+				return IsLongTerm(a, asOf)
+			}
+			return a.CostPerShare > b.CostPerShare
+		}
+	default:
+		return fmt.Errorf("invalid lot method: %s", method)
+	}
+
+	sort.SliceStable(lots, func(i, j int) bool {
+		return less(lots[i], lots[j])
+	})
+
+	return nil
+}
+
+func contains(symbols []string, symbol string) bool {
+	for _, s := range symbols {
+		if s == symbol {
+			return true
+		}
+	}
+	return false
+}
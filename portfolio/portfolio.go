@@ -0,0 +1,205 @@
+package portfolio
+
+import (
+	"sort"
+	"time"
+
+	"github.com/samkreter/portfoli/pkg/importer"
+	"github.com/samkreter/portfoli/pkg/money"
+)
+
+// Portfolio holds the positions of every account across all imported files
+type Portfolio struct {
+	Positions []importer.Position
+
+	// Transactions is the imported activity history of every account
+	Transactions []importer.Transaction
+}
+
+// Holding is a symbol aggregated across every account holding it
+type Holding struct {
+	Symbol    string
+	Quantity  float64
+	LastPrice money.Money
+	Value     money.Money
+
+	// Accounts holds the position in each account, in import order
+	Accounts []importer.Position
+}
+
+// New creates a portfolio from the imported positions
+func New(positions []importer.Position) *Portfolio {
+	return &Portfolio{
+		Positions: positions,
+	}
+}
+
+// Add adds more imported positions to the portfolio
+func (p *Portfolio) Add(positions ...importer.Position) {
+	p.Positions = append(p.Positions, positions...)
+}
+
+// AddTransactions adds imported account history to the portfolio
+func (p *Portfolio) AddTransactions(transactions ...importer.Transaction) {
+	p.Transactions = append(p.Transactions, transactions...)
+}
+
+// AddLots attaches imported tax lots to the positions of the same account and
+// symbol, returning the lots no position matched
+func (p *Portfolio) AddLots(lots ...importer.Lot) []importer.Lot {
+	unmatched := []importer.Lot{}
+	for _, lot := range lots {
+		matched := false
+		for idx := range p.Positions {
+			position := &p.Positions[idx]
+			if position.Account == lot.Account && position.Symbol == lot.Symbol {
+				position.Lots = append(position.Lots, lot)
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			unmatched = append(unmatched, lot)
+		}
+	}
+
+	return unmatched
+}
+
+// HasLots reports whether any position has tax lots
+func (p *Portfolio) HasLots() bool {
+	for _, position := range p.Positions {
+		if len(position.Lots) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Accounts returns the sorted names of all accounts in the portfolio
+func (p *Portfolio) Accounts() []string {
+	seen := map[string]bool{}
+	accounts := []string{}
+	for _, position := range p.Positions {
+		if !seen[position.Account] {
+			seen[position.Account] = true
+			accounts = append(accounts, position.Account)
+		}
+	}
+	sort.Strings(accounts)
+
+	return accounts
+}
+
+// AccountPositions returns the positions held in account
+func (p *Portfolio) AccountPositions(account string) []importer.Position {
+	positions := []importer.Position{}
+	for _, position := range p.Positions {
+		if position.Account == account {
+			positions = append(positions, position)
+		}
+	}
+
+	return positions
+}
+
+// Holdings returns every symbol aggregated across accounts, sorted by symbol
+func (p *Portfolio) Holdings() []*Holding {
+	bySymbol := map[string]*Holding{}
+	holdings := []*Holding{}
+	for _, position := range p.Positions {
+		holding, ok := bySymbol[position.Symbol]
+		if !ok {
+			holding = &Holding{Symbol: position.Symbol}
+			bySymbol[position.Symbol] = holding
+			holdings = append(holdings, holding)
+		}
+
+		holding.Quantity += position.Quantity
+		if holding.LastPrice == 0 {
+			holding.LastPrice = position.LastPrice
+		}
+		holding.Value += position.Value
+		holding.Accounts = append(holding.Accounts, position)
+	}
+
+	sort.Slice(holdings, func(i, j int) bool {
+		return holdings[i].Symbol < holdings[j].Symbol
+	})
+
+	return holdings
+}
+
+// Holding returns the symbol aggregated across accounts, ok is false if it is not held
+func (p *Portfolio) Holding(symbol string) (*Holding, bool) {
+	for _, holding := range p.Holdings() {
+		if holding.Symbol == symbol {
+			return holding, true
+		}
+	}
+
+	return nil, false
+}
+
+// Values returns the total value held of each symbol
+func (p *Portfolio) Values() map[string]money.Money {
+	values := map[string]money.Money{}
+	for _, position := range p.Positions {
+		values[position.Symbol] += position.Value
+	}
+
+	return values
+}
+
+// AccountValues returns the value of each symbol held, keyed by account
+func (p *Portfolio) AccountValues() map[string]map[string]money.Money {
+	accountValues := map[string]map[string]money.Money{}
+	for _, position := range p.Positions {
+		if _, ok := accountValues[position.Account]; !ok {
+			accountValues[position.Account] = map[string]money.Money{}
+		}
+		accountValues[position.Account][position.Symbol] += position.Value
+	}
+
+	return accountValues
+}
+
+// Quantities returns the total quantity held of each symbol
+func (p *Portfolio) Quantities() map[string]float64 {
+	quantities := map[string]float64{}
+	for _, position := range p.Positions {
+		quantities[position.Symbol] += position.Quantity
+	}
+
+	return quantities
+}
+
+// Prices returns the last price of each symbol
+func (p *Portfolio) Prices() map[string]money.Money {
+	prices := map[string]money.Money{}
+	for _, position := range p.Positions {
+		if _, ok := prices[position.Symbol]; !ok && position.LastPrice > 0 {
+			prices[position.Symbol] = position.LastPrice
+		}
+	}
+
+	return prices
+}
+
+// TotalValue returns the value of every position in the portfolio
+func (p *Portfolio) TotalValue() money.Money {
+	total := money.Money(0)
+	for _, position := range p.Positions {
+		total += position.Value
+	}
+
+	return total
+}
+
+// day returns midnight UTC of the calendar day of t, so dates compare by day
+// whatever their time of day and location
+func day(t time.Time) time.Time {
+	year, month, date := t.Date()
+	return time.Date(year, month, date, 0, 0, 0, 0, time.UTC)
+}
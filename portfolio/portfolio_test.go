@@ -0,0 +1,244 @@
+package portfolio
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/samkreter/portfoli/pkg/importer"
+	"github.com/samkreter/portfoli/pkg/money"
+)
+
+func TestCheckWashSaleWindow(t *testing.T) {
+	// Sold mid-afternoon, purchase dates are imported as midnight
+	asOf := time.Date(2024, 3, 31, 15, 30, 0, 0, time.Local)
+
+	tests := []struct {
+		daysBefore int
+		washSale   bool
+	}{
+		{0, true},
+		{30, true},
+		{31, false},
+	}
+	for _, test := range tests {
+		p := New(nil)
+		p.AddTransactions(importer.Transaction{
+			Date:   time.Date(2024, 3, 31-test.daysBefore, 0, 0, 0, 0, time.UTC),
+			Type:   importer.Buy,
+			Symbol: "VTI",
+		})
+
+		if check := p.CheckWashSale([]string{"VTI"}, asOf); check.IsWashSale() != test.washSale {
+			t.Errorf("purchase %d days before the sale: expected wash sale %v, got %v", test.daysBefore, test.washSale, check.IsWashSale())
+		}
+	}
+}
+
+func TestCheckWashSaleLatestPurchase(t *testing.T) {
+	p := New(nil)
+	p.AddTransactions(
+		importer.Transaction{Date: time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC), Type: importer.Buy, Symbol: "VTI"},
+		importer.Transaction{Date: time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC), Type: importer.Reinvestment, Symbol: "VTI"},
+	)
+
+	check := p.CheckWashSale([]string{"VTI"}, time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC))
+	if latest := check.LatestPurchase(); latest.Date.Day() != 20 {
+		t.Errorf("expected the 2024-03-20 purchase, got %s", latest.Date.Format("2006-01-02"))
+	}
+	if expected := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC); !check.RepurchaseAfter.Equal(expected) {
+		t.Errorf("expected to repurchase after %s, got %s", expected.Format("2006-01-02"), check.RepurchaseAfter.Format("2006-01-02"))
+	}
+}
+
+func TestIsLongTerm(t *testing.T) {
+	lot := importer.Lot{Acquired: time.Date(2023, 3, 31, 0, 0, 0, 0, time.UTC)}
+
+	tests := []struct {
+		asOf     time.Time
+		longTerm bool
+	}{
+		{time.Date(2024, 3, 30, 23, 0, 0, 0, time.Local), false},
+		{time.Date(2024, 3, 31, 23, 0, 0, 0, time.Local), false},
+		{time.Date(2024, 4, 1, 9, 0, 0, 0, time.Local), true},
+	}
+	for _, test := range tests {
+		if longTerm := IsLongTerm(lot, test.asOf); longTerm != test.longTerm {
+			t.Errorf("sold %s: expected long-term %v, got %v", test.asOf.Format("2006-01-02"), test.longTerm, longTerm)
+		}
+	}
+}
+
+func TestSelectLots(t *testing.T) {
+	asOf := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	lots := []importer.Lot{
+		{Symbol: "VTI", Acquired: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), Quantity: 10, CostPerShare: 100 * money.Unit},
+		{Symbol: "VTI", Acquired: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), Quantity: 10, CostPerShare: 250 * money.Unit},
+		{Symbol: "FSKAX", Acquired: time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC), Quantity: 10, CostPerShare: 130 * money.Unit},
+		{Symbol: "FSKAX", Acquired: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), Quantity: 10, CostPerShare: 110 * money.Unit},
+	}
+	prices := map[string]money.Money{"VTI": 200 * money.Unit, "FSKAX": 120 * money.Unit}
+
+	p := New([]importer.Position{
+		{Account: "Brokerage", Symbol: "VTI", Lots: lots[:2]},
+		{Account: "Brokerage", Symbol: "FSKAX", Lots: lots[2:]},
+	})
+
+	tests := []struct {
+		method   LotMethod
+		acquired []string
+	}{
+		{HIFO, []string{"2024-02-01", "2022-05-01"}},
+		{FIFO, []string{"2020-01-02", "2022-05-01"}},
+		{MinGain, []string{"2024-02-01", "2022-05-01"}},
+		{LongTermFirst, []string{"2022-05-01", "2020-01-02"}},
+	}
+	for _, test := range tests {
+		// 3200 is exactly the first two lots, each priced at its own symbol
+		estimate, err := p.SelectLots([]string{"VTI", "FSKAX"}, 3200*money.Unit, prices, test.method, asOf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		acquired := []string{}
+		for _, sale := range estimate.Sales {
+			acquired = append(acquired, sale.Lot.Acquired.Format("2006-01-02"))
+		}
+		if len(acquired) < len(test.acquired) {
+			t.Errorf("%s: expected lots %v, got %v", test.method, test.acquired, acquired)
+			continue
+		}
+		for idx := range test.acquired {
+			if acquired[idx] != test.acquired[idx] {
+				t.Errorf("%s: expected lots %v, got %v", test.method, test.acquired, acquired)
+				break
+			}
+		}
+	}
+
+	// The VTI lot bought at 250 is sold at VTI's price, not FSKAX's
+	estimate, err := p.SelectLots([]string{"VTI", "FSKAX"}, 2000*money.Unit, prices, HIFO, asOf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(estimate.Sales) != 1 || estimate.Sales[0].Shares != 10 || estimate.ShortTermGain != -500*money.Unit {
+		t.Errorf("unexpected HIFO estimate: %+v", estimate)
+	}
+}
+
+func TestSelectLotsSkipsUnpricedSymbols(t *testing.T) {
+	p := New([]importer.Position{{Symbol: "FSKAX", Lots: []importer.Lot{{Symbol: "FSKAX", Quantity: 10, CostPerShare: money.Unit}}}})
+
+	estimate, err := p.SelectLots([]string{"VTI", "FSKAX"}, 100*money.Unit, map[string]money.Money{}, HIFO, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(estimate.Sales) != 0 || len(estimate.Unpriced) != 1 || estimate.Uncovered != 100*money.Unit {
+		t.Errorf("expected the FSKAX lot to be skipped, got %+v", estimate)
+	}
+}
+
+func TestLoadAccounts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "portfoli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "accounts.json")
+	if _, err := LoadAccounts(path); !os.IsNotExist(err) {
+		t.Errorf("expected a missing file error, got %v", err)
+	}
+
+	if err := ioutil.WriteFile(path, []byte(`{"accounts": [{"name": "IRA", "taxType": "traditional"}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	taxTypes, err := LoadAccounts(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if taxTypes["IRA"] != Traditional {
+		t.Errorf("expected IRA to be %s, got %q", Traditional, taxTypes["IRA"])
+	}
+}
+
+func TestHoldingsSumAccountsAndFiles(t *testing.T) {
+	// Each file is added the way main imports them, VTI is in both accounts of both files
+	p := New(nil)
+	p.Add(
+		importer.Position{Account: "Brokerage", Symbol: "VTI", Quantity: 10, LastPrice: 150 * money.Unit, Value: 1500 * money.Unit},
+		importer.Position{Account: "IRA", Symbol: "VTI", Quantity: 5, LastPrice: 150 * money.Unit, Value: 750 * money.Unit},
+	)
+	p.Add(
+		importer.Position{Account: "Brokerage", Symbol: "VTI", Quantity: 2, LastPrice: 150 * money.Unit, Value: 300 * money.Unit},
+		importer.Position{Account: "Roth", Symbol: "VTI", Quantity: 1, LastPrice: 150 * money.Unit, Value: 150 * money.Unit},
+		importer.Position{Account: "Roth", Symbol: "VGIT", Quantity: 4, LastPrice: 60 * money.Unit, Value: 240 * money.Unit},
+	)
+
+	holdings := p.Holdings()
+	if len(holdings) != 2 {
+		t.Fatalf("expected VGIT and VTI, got %+v", holdings)
+	}
+	vti := holdings[1]
+	if vti.Symbol != "VTI" || vti.Quantity != 18 || vti.Value != 2700*money.Unit || len(vti.Accounts) != 4 {
+		t.Errorf("expected 18 VTI worth 2700 across 4 positions, got %+v", vti)
+	}
+
+	if values := p.Values(); values["VTI"] != 2700*money.Unit || values["VGIT"] != 240*money.Unit {
+		t.Errorf("expected VTI worth 2700 and VGIT 240, got %v", values)
+	}
+	if quantities := p.Quantities(); quantities["VTI"] != 18 {
+		t.Errorf("expected 18 VTI, got %v", quantities)
+	}
+
+	accountValues := p.AccountValues()
+	expected := map[string]money.Money{"Brokerage": 1800 * money.Unit, "IRA": 750 * money.Unit, "Roth": 150 * money.Unit}
+	for account, value := range expected {
+		if accountValues[account]["VTI"] != value {
+			t.Errorf("%s: expected VTI worth %s, got %s", account, value, accountValues[account]["VTI"])
+		}
+	}
+}
+
+func TestCashExcludingPlanSymbols(t *testing.T) {
+	p := New([]importer.Position{
+		{Account: "Brokerage", Symbol: "SPAXX", Value: 500 * money.Unit},
+		{Account: "Brokerage", Symbol: importer.CashSymbol, Value: 100 * money.Unit},
+		{Account: "Brokerage", Symbol: "VTI", Value: 1000 * money.Unit},
+	})
+
+	if cash := p.CashExcluding([]string{"VTI", "SPAXX"}); cash != 100*money.Unit {
+		t.Errorf("expected 100 of cash outside the plan, got %s", cash)
+	}
+}
+
+func TestHarvestCandidates(t *testing.T) {
+	asOf := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	p := New([]importer.Position{
+		// Only the lot bought at 250 is at a loss
+		{Account: "Brokerage", Symbol: "VTI", LastPrice: 200 * money.Unit, Value: 4000 * money.Unit, Lots: []importer.Lot{
+			{Symbol: "VTI", Acquired: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), Quantity: 10, CostPerShare: 100 * money.Unit},
+			{Symbol: "VTI", Acquired: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), Quantity: 10, CostPerShare: 250 * money.Unit},
+		}},
+		// Without lots the position's cost basis is used
+		{Account: "Brokerage", Symbol: "VEA", Value: 1000 * money.Unit, CostBasis: 1800 * money.Unit},
+		{Account: "Brokerage", Symbol: "VGIT", Value: 1000 * money.Unit, CostBasis: 1100 * money.Unit},
+		{Account: "IRA", Symbol: "VWO", Value: 1000 * money.Unit, CostBasis: 3000 * money.Unit},
+	})
+
+	candidates := p.HarvestCandidates(500*money.Unit, map[string]TaxType{"IRA": Traditional}, asOf)
+
+	// VGIT's loss is under the threshold and the IRA isn't taxable
+	if len(candidates) != 2 {
+		t.Fatalf("expected VEA and VTI, got %+v", candidates)
+	}
+	if vea := candidates[0]; vea.Symbol != "VEA" || vea.Loss != -800*money.Unit || vea.Value != 1000*money.Unit {
+		t.Errorf("unexpected VEA candidate: %+v", vea)
+	}
+	vti := candidates[1]
+	if vti.Symbol != "VTI" || vti.Loss != -500*money.Unit || vti.Value != 2000*money.Unit || len(vti.Lots) != 1 || vti.Lots[0].LongTerm {
+		t.Errorf("unexpected VTI candidate: %+v", vti)
+	}
+}
@@ -0,0 +1,92 @@
+package portfolio
+
+import (
+	"time"
+
+	"github.com/samkreter/portfoli/pkg/importer"
+	"github.com/samkreter/portfoli/pkg/money"
+)
+
+const (
+	// washSaleDays is how many days before or after a sale at a loss a purchase
+	// of a substantially identical security disallows the loss
+	washSaleDays = 30
+)
+
+// WashSaleCheck is the result of checking a sale at a loss for wash sales
+type WashSaleCheck struct {
+	Symbol string
+
+	// Purchases are the purchases, in any account, that would make the sale a wash sale
+	Purchases []importer.Transaction
+
+	// RepurchaseAfter is the first day the symbols can be bought again without a wash sale
+	RepurchaseAfter time.Time
+}
+
+// IsWashSale reports whether selling at a loss now would be a wash sale
+func (c WashSaleCheck) IsWashSale() bool {
+	return len(c.Purchases) > 0
+}
+
+// LatestPurchase returns the most recent purchase that makes the sale a wash sale
+func (c WashSaleCheck) LatestPurchase() importer.Transaction {
+	latest := c.Purchases[0]
+	for _, purchase := range c.Purchases[1:] {
+		if purchase.Date.After(latest.Date) {
+			latest = purchase
+		}
+	}
+	return latest
+}
+
+// CheckWashSale checks a sale at a loss of symbols on the day of asOf against
+// the imported history. symbols are the symbol sold and every substantially
+// identical one, purchases of any of them in any account from 30 days before
+// the sale through the day of the sale, including reinvested dividends, make
+// it a wash sale.
+func (p *Portfolio) CheckWashSale(symbols []string, asOf time.Time) WashSaleCheck {
+	saleDay := day(asOf)
+	check := WashSaleCheck{
+		Symbol:          symbols[0],
+		RepurchaseAfter: saleDay.AddDate(0, 0, washSaleDays+1),
+	}
+
+	windowStart := saleDay.AddDate(0, 0, -washSaleDays)
+	for _, transaction := range p.Transactions {
+		purchaseDay := day(transaction.Date)
+		if !transaction.IsPurchase() || purchaseDay.Before(windowStart) || purchaseDay.After(saleDay) {
+			continue
+		}
+
+		for _, symbol := range symbols {
+			if transaction.Symbol == symbol {
+				check.Purchases = append(check.Purchases, transaction)
+				break
+			}
+		}
+	}
+
+	return check
+}
+
+// UnrealizedGain estimates the gain of selling amount of the symbols from the
+// positions' cost basis, spread evenly over the value held. ok is false when
+// the cost basis of the symbols is not known.
+func (p *Portfolio) UnrealizedGain(symbols []string, amount money.Money) (gain money.Money, ok bool) {
+	value, costBasis := money.Money(0), money.Money(0)
+	for _, position := range p.Positions {
+		for _, symbol := range symbols {
+			if position.Symbol == symbol {
+				value += position.Value
+				costBasis += position.CostBasis
+			}
+		}
+	}
+
+	if value == 0 || costBasis == 0 {
+		return 0, false
+	}
+
+	return (value - costBasis).Mul(amount.Ratio(value)), true
+}